@@ -0,0 +1,281 @@
+package freebot
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/fatedier/freebot/pkg/config"
+	"github.com/fatedier/freebot/pkg/deadletter"
+	"github.com/fatedier/freebot/pkg/httputil"
+	"github.com/fatedier/freebot/pkg/log"
+	"github.com/fatedier/freebot/pkg/metrics"
+	"github.com/fatedier/freebot/plugin"
+)
+
+var (
+	ErrUnauthorized     = httputil.NewHttpError(401, "unauthorized")
+	ErrMethodNotAllowed = httputil.NewHttpError(405, "method not allowed")
+)
+
+// AdminHandler serves the admin api and prometheus metrics, all requests except probes require header "Authorization: Bearer {admin_token}".
+func (svc *Service) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", svc.handleHealthz)
+	mux.HandleFunc("/readyz", svc.handleReadyz)
+	mux.Handle("/metrics", metrics.Default.Handler())
+	mux.HandleFunc("/api/repos", svc.handleListRepos)
+	mux.HandleFunc("/api/config/effective", svc.handleEffectiveConfig)
+	mux.HandleFunc("/api/config/resolved", svc.handleResolvedConfig)
+	mux.HandleFunc("/api/config/errors", svc.handleConfigErrors)
+	mux.HandleFunc("/api/dryrun", svc.handleListDryRunResults)
+	mux.HandleFunc("/api/reload", svc.handleReload)
+	mux.HandleFunc("/api/failed", svc.handleListFailedEvents)
+	mux.HandleFunc("/api/failed/replay", svc.handleReplayFailedEvent)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// probes are not authorized
+		if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		if token := svc.adminToken(); token != "" &&
+			subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			httputil.ReplyError(w, ErrUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+type PluginInfo struct {
+	Name  string      `json:"name"`
+	Extra interface{} `json:"extra"`
+}
+
+type RepoInfo struct {
+	Repo    string       `json:"repo"`
+	Plugins []PluginInfo `json:"plugins"`
+}
+
+// Ready returns nil if github client is initialized and app installations are loaded when using github app.
+func (svc *Service) Ready() error {
+	svc.mu.RLock()
+	auth := svc.auth
+	svc.mu.RUnlock()
+
+	if auth.cli == nil {
+		return fmt.Errorf("github client is not initialized")
+	}
+	if auth.appTransport != nil && len(auth.appTransport.InstallIDs()) == 0 {
+		return fmt.Errorf("no github app installations")
+	}
+	return nil
+}
+
+// RepoInfos returns active plugins of repos, plugins of repos matching patterns are included after created.
+func (svc *Service) RepoInfos() []RepoInfo {
+	all := svc.eventHandler.AllPlugins()
+	out := make([]RepoInfo, 0, len(all))
+	for repo, plugins := range all {
+		info := RepoInfo{
+			Repo:    repo,
+			Plugins: make([]PluginInfo, 0, len(plugins)),
+		}
+		for _, p := range plugins {
+			pluginInfo := PluginInfo{
+				Name: p.Name(),
+			}
+			if v, ok := p.(plugin.ParsedExtraInterface); ok {
+				pluginInfo.Extra = config.RedactValue(v.ParsedExtra())
+			}
+			info.Plugins = append(info.Plugins, pluginInfo)
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Repo < out[j].Repo
+	})
+	return out
+}
+
+// EffectiveRepoConfs returns repo confs merged from repo_confs and repo_conf_dir, secrets are redacted.
+func (svc *Service) EffectiveRepoConfs() map[string]RepoConf {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+
+	out := make(map[string]RepoConf, len(svc.repoConfs))
+	for k, v := range svc.repoConfs {
+		if v.WebhookSecret != "" {
+			v.WebhookSecret = config.RedactedValue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// RepoConfErrors returns errors of repo conf files, repo confs and plugins not recovered yet.
+func (svc *Service) RepoConfErrors() []RepoConfError {
+	return svc.confErrors.List()
+}
+
+// DryRunResults returns recent events handled by dry-run plugins of the repo, newest first.
+func (svc *Service) DryRunResults(repo string) []DryRunResult {
+	return svc.eventHandler.DryRunResults(repo)
+}
+
+// GET /healthz
+func (svc *Service) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	httputil.ReplyJSON(w, 200, map[string]string{
+		"status": "ok",
+	})
+}
+
+// GET /readyz
+func (svc *Service) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	if err := svc.Ready(); err != nil {
+		httputil.ReplyError(w, httputil.NewHttpError(503, err.Error()))
+		return
+	}
+	httputil.ReplyJSON(w, 200, map[string]string{
+		"status": "ok",
+	})
+}
+
+// GET /api/repos
+func (svc *Service) handleListRepos(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.ReplyError(w, ErrMethodNotAllowed)
+		return
+	}
+	httputil.ReplyJSON(w, 200, svc.RepoInfos())
+}
+
+// GET /api/config/effective
+func (svc *Service) handleEffectiveConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.ReplyError(w, ErrMethodNotAllowed)
+		return
+	}
+	httputil.ReplyJSON(w, 200, map[string]interface{}{
+		"repo_confs": config.RedactValue(svc.EffectiveRepoConfs()),
+	})
+}
+
+// GET /api/config/resolved?repo={owner}/{repo}
+func (svc *Service) handleResolvedConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.ReplyError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	arrs := strings.Split(r.URL.Query().Get("repo"), "/")
+	if len(arrs) != 2 || arrs[0] == "" || arrs[1] == "" {
+		httputil.ReplyError(w, httputil.NewHttpError(400, "repo should be {owner}/{repo}"))
+		return
+	}
+
+	conf, key, ok, err := svc.ResolvedRepoConf(arrs[0], arrs[1])
+	if err != nil {
+		httputil.ReplyError(w, httputil.NewHttpError(500, err.Error()))
+		return
+	}
+	if !ok {
+		httputil.ReplyError(w, httputil.NewHttpError(404, "no conf of this repo"))
+		return
+	}
+	httputil.ReplyJSON(w, 200, map[string]interface{}{
+		"repo": arrs[0] + "/" + arrs[1],
+		"key":  key,
+		"conf": config.RedactValue(conf),
+	})
+}
+
+// GET /api/config/errors
+func (svc *Service) handleConfigErrors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.ReplyError(w, ErrMethodNotAllowed)
+		return
+	}
+	httputil.ReplyJSON(w, 200, svc.RepoConfErrors())
+}
+
+// GET /api/dryrun?repo={owner}/{repo}
+func (svc *Service) handleListDryRunResults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.ReplyError(w, ErrMethodNotAllowed)
+		return
+	}
+	httputil.ReplyJSON(w, 200, svc.DryRunResults(r.URL.Query().Get("repo")))
+}
+
+// POST /api/reload
+func (svc *Service) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httputil.ReplyError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	changed, err := svc.ReloadRepoConfs(true)
+	if err != nil {
+		log.Warn("reload repo confs error: %v", err)
+		httputil.ReplyError(w, httputil.NewHttpError(500, err.Error()))
+		return
+	}
+	httputil.ReplyJSON(w, 200, map[string]interface{}{
+		"changed": changed,
+	})
+}
+
+// GET /api/failed
+func (svc *Service) handleListFailedEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httputil.ReplyError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	entries, err := svc.ListFailedEvents()
+	if err != nil {
+		httputil.ReplyError(w, err)
+		return
+	}
+	httputil.ReplyJSON(w, 200, entries)
+}
+
+// POST /api/failed/replay?id={id}
+func (svc *Service) handleReplayFailedEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httputil.ReplyError(w, ErrMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		httputil.ReplyError(w, httputil.NewHttpError(400, "id is required"))
+		return
+	}
+
+	// replay should not be cancelled if the client disconnects
+	entry, err := svc.ReplayFailedEvent(context.Background(), id)
+	if err == deadletter.ErrNotFound {
+		httputil.ReplyError(w, httputil.NewHttpError(404, fmt.Sprintf("failed event [%s] not found", id)))
+		return
+	}
+	if entry == nil {
+		httputil.ReplyError(w, err)
+		return
+	}
+	if err != nil {
+		log.Warn("replay failed event [%s] error: %v", id, err)
+	}
+
+	entry.Payload = ""
+	httputil.ReplyJSON(w, 200, map[string]interface{}{
+		"success": err == nil,
+		"event":   entry,
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fatedier/freebot/pkg/deadletter"
+)
+
+var (
+	replayFailedID  string
+	replayList      bool
+	replayAdminAddr string
+)
+
+func init() {
+	replayCmd.Flags().StringVarP(&replayFailedID, "failed", "", "", "id of the failed event to replay")
+	replayCmd.Flags().BoolVarP(&replayList, "list", "l", false, "list failed events")
+	replayCmd.Flags().StringVarP(&replayAdminAddr, "admin-addr", "", "", "address of the admin api of the running freebot, admin_bind_addr is used if not set")
+	rootCmd.AddCommand(replayCmd)
+}
+
+// replayCmd talks to the admin api of the running freebot, so the dedup file, the event queue
+// and the dead letter dir are only touched by one process.
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "List or replay events failed to be handled by plugins",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cfgFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		addr := replayAdminAddr
+		if addr == "" {
+			addr = cfg.AdminBindAddr
+		}
+		if addr == "" {
+			return fmt.Errorf("admin_bind_addr is not set, replay requires the admin api of the running freebot")
+		}
+		cli := &adminClient{
+			addr:  dialAddr(addr),
+			token: cfg.AdminToken,
+			cli:   &http.Client{Timeout: 5 * time.Minute},
+		}
+
+		if replayList {
+			entries := make([]deadletter.Entry, 0)
+			if err := cli.do(http.MethodGet, "/api/failed", &entries); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			for _, e := range entries {
+				fmt.Printf("%s\t%s\t%s\t%s\t%v\n", e.ID, e.FailedAt.Format("2006-01-02 15:04:05"), e.Repo, e.EventType, e.FailedPlugins())
+			}
+			return nil
+		}
+
+		if replayFailedID == "" {
+			return fmt.Errorf("--failed or --list is required")
+		}
+
+		result := struct {
+			Success bool              `json:"success"`
+			Event   *deadletter.Entry `json:"event"`
+		}{}
+		err = cli.do(http.MethodPost, "/api/failed/replay?id="+url.QueryEscape(replayFailedID), &result)
+		if err != nil {
+			fmt.Printf("replay failed event [%s] error: %v\n", replayFailedID, err)
+			os.Exit(1)
+		}
+		if result.Event != nil {
+			buf, _ := json.MarshalIndent(result.Event, "", "    ")
+			fmt.Println(string(buf))
+		}
+		if !result.Success {
+			fmt.Printf("replay failed event [%s] error, see errors of the event\n", replayFailedID)
+			os.Exit(1)
+		}
+		fmt.Printf("replay failed event [%s] success\n", replayFailedID)
+		return nil
+	},
+}
+
+type adminClient struct {
+	addr  string
+	token string
+	cli   *http.Client
+}
+
+// do sends the request to the admin api and decodes the json response into out.
+func (c *adminClient) do(method string, uri string, out interface{}) error {
+	req, err := http.NewRequest(method, "http://"+c.addr+uri, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		errResp := struct {
+			Error string `json:"error"`
+		}{}
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("admin api error(%d): %s", resp.StatusCode, errResp.Error)
+		}
+		return fmt.Errorf("admin api error(%d)", resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// dialAddr replaces the unspecified host of a listen address with the loopback address.
+func dialAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -1,14 +1,13 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 
 	"github.com/spf13/cobra"
 
 	"github.com/fatedier/freebot"
+	"github.com/fatedier/freebot/pkg/config"
 )
 
 const (
@@ -21,7 +20,7 @@ var (
 )
 
 func init() {
-	rootCmd.PersistentFlags().StringVarP(&cfgFile, "", "c", "", "config file of freebot")
+	rootCmd.PersistentFlags().StringVarP(&cfgFile, "", "c", "", "config file of freebot, json or yaml by extension")
 	rootCmd.PersistentFlags().BoolVarP(&showVersion, "version", "v", false, "version of freebot")
 }
 
@@ -34,31 +33,35 @@ var rootCmd = &cobra.Command{
 			return nil
 		}
 
-		content, err := ioutil.ReadFile(cfgFile)
+		cfg, err := loadConfig(cfgFile)
 		if err != nil {
 			fmt.Println(err)
 			return nil
 		}
 
-		cfg := freebot.Config{}
-		err = json.Unmarshal(content, &cfg)
+		svc, err := freebot.NewService(cfg)
 		if err != nil {
-			fmt.Printf("parse config file error: %v\n", err)
+			fmt.Println(err)
 			return nil
 		}
+		svc.SetConfigLoader(func() (freebot.Config, error) {
+			return loadConfig(cfgFile)
+		})
 
-		svc, err := freebot.NewService(cfg)
+		err = svc.Run()
 		if err != nil {
 			fmt.Println(err)
-			return nil
 		}
-
-		svc.Run()
-
 		return nil
 	},
 }
 
+// loadConfig parses the config file as YAML if its extension is .yaml or .yml, otherwise as JSON.
+func loadConfig(path string) (cfg freebot.Config, err error) {
+	err = config.LoadFile(path, &cfg)
+	return
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fatedier/freebot"
+	"github.com/fatedier/freebot/pkg/config"
+)
+
+var (
+	resolveRepo string
+)
+
+func init() {
+	validateCmd.Flags().StringVarP(&resolveRepo, "repo", "r", "", "print the conf of this repo({owner}/{repo}) resolved with templates and org defaults")
+	rootCmd.AddCommand(validateCmd)
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file and repo confs, exit with non-zero code if there are problems",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if cfgFile == "" {
+			return fmt.Errorf("config file is required")
+		}
+
+		problems := freebot.ValidateConfigFile(cfgFile)
+		for _, p := range problems {
+			fmt.Println(p.String())
+		}
+
+		if resolveRepo != "" {
+			conf, key, err := freebot.ResolveRepoConfFile(cfgFile, resolveRepo)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			// the output may be kept in ci logs
+			buf, _ := json.MarshalIndent(config.RedactValue(conf), "", "    ")
+			fmt.Printf("conf of [%s] resolved from [%s]:\n%s\n", resolveRepo, key, string(buf))
+		}
+
+		if len(problems) > 0 {
+			fmt.Printf("%d problems found\n", len(problems))
+			os.Exit(1)
+		}
+		fmt.Println("config is valid")
+		return nil
+	},
+}
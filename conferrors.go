@@ -0,0 +1,166 @@
+package freebot
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fatedier/freebot/pkg/log"
+	"github.com/fatedier/freebot/pkg/metrics"
+	"github.com/fatedier/freebot/plugin"
+)
+
+// RepoConfError is the error of loading a file in repo_conf_dir, resolving a repo conf or creating plugins of a repo.
+// Key is the file, the repo conf key or owner/repo. The last good conf or plugins are still used if KeepLastGood is true.
+type RepoConfError struct {
+	Key          string    `json:"key"`
+	Error        string    `json:"error"`
+	KeepLastGood bool      `json:"keep_last_good"`
+	Since        time.Time `json:"since"`
+}
+
+func newRepoConfError(key string, err error, keepLastGood bool) *RepoConfError {
+	return &RepoConfError{
+		Key:          key,
+		Error:        err.Error(),
+		KeepLastGood: keepLastGood,
+	}
+}
+
+// repoConfErrors records errors of repo confs so that they can be found from admin api and metrics.
+type repoConfErrors struct {
+	errors map[string]*RepoConfError
+	mu     sync.Mutex
+}
+
+func newRepoConfErrors() *repoConfErrors {
+	return &repoConfErrors{
+		errors: make(map[string]*RepoConfError),
+	}
+}
+
+// Set records the error, Since is kept if the key is already failing.
+func (r *repoConfErrors) Set(e *RepoConfError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set(e)
+}
+
+func (r *repoConfErrors) set(err *RepoConfError) {
+	// errors passed in are compared by callers to find changes, they are not modified
+	e := *err
+	e.Since = time.Now()
+	if exist, ok := r.errors[e.Key]; ok {
+		e.Since = exist.Since
+	}
+	r.errors[e.Key] = &e
+
+	if e.KeepLastGood {
+		log.Error("[%s] %s, the last good conf is still used", e.Key, e.Error)
+	} else {
+		log.Error("[%s] %s", e.Key, e.Error)
+	}
+	metrics.RepoConfErrors.Set(1, e.Key)
+	metrics.RepoConfBuildFailures.Inc(e.Key)
+}
+
+// Clear drops the error of key if it exists.
+func (r *repoConfErrors) Clear(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clear(key)
+}
+
+func (r *repoConfErrors) clear(key string) {
+	if _, ok := r.errors[key]; !ok {
+		return
+	}
+	delete(r.errors, key)
+	log.Info("[%s] recovered from error", key)
+	metrics.RepoConfErrors.Set(0, key)
+}
+
+// Reset replaces all errors by errs after all repo confs are rebuilt.
+func (r *repoConfErrors) Reset(errs map[string]*RepoConfError) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key := range r.errors {
+		if _, ok := errs[key]; !ok {
+			r.clear(key)
+		}
+	}
+	for _, key := range sortedRepoConfErrorKeys(errs) {
+		r.set(errs[key])
+	}
+}
+
+// List returns errors ordered by key.
+func (r *repoConfErrors) List() []RepoConfError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]RepoConfError, 0, len(r.errors))
+	for _, key := range sortedRepoConfErrorKeys(r.errors) {
+		out = append(out, *r.errors[key])
+	}
+	return out
+}
+
+func sortedRepoConfErrorKeys(m map[string]*RepoConfError) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mergeRepoConfErrors returns errors in all maps, the first error of a key is kept
+// since later errors are usually caused by it.
+func mergeRepoConfErrors(errs ...map[string]*RepoConfError) map[string]*RepoConfError {
+	out := make(map[string]*RepoConfError)
+	for _, m := range errs {
+		for k, v := range m {
+			if _, ok := out[k]; !ok {
+				out[k] = v
+			}
+		}
+	}
+	return out
+}
+
+// goodPlugins keeps plugins last created successfully for each repo,
+// they are used if creating plugins with a new conf fails.
+type goodPlugins struct {
+	plugins map[string][]plugin.Plugin
+	mu      sync.Mutex
+}
+
+func newGoodPlugins() *goodPlugins {
+	return &goodPlugins{
+		plugins: make(map[string][]plugin.Plugin),
+	}
+}
+
+func (g *goodPlugins) Get(repo string) ([]plugin.Plugin, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	plugins, ok := g.plugins[repo]
+	return plugins, ok
+}
+
+func (g *goodPlugins) Set(repo string, plugins []plugin.Plugin) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.plugins[repo] = plugins
+}
+
+// Prune drops plugins of repos for which keep returns false.
+func (g *goodPlugins) Prune(keep func(repo string) bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for repo := range g.plugins {
+		if !keep(repo) {
+			delete(g.plugins, repo)
+		}
+	}
+}
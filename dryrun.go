@@ -0,0 +1,86 @@
+package freebot
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatedier/freebot/pkg/client"
+)
+
+// maxDryRunResults is the number of recent events handled by dry-run plugins kept in memory.
+const maxDryRunResults = 200
+
+// DryRunResult is an event handled by dry-run plugins, only results of dry-run plugins are kept.
+type DryRunResult struct {
+	Time    time.Time      `json:"time"`
+	Event   string         `json:"event"`
+	Action  string         `json:"action,omitempty"`
+	Repo    string         `json:"repo"`
+	Plugins []PluginResult `json:"plugins"`
+}
+
+// dryRunResults keeps recent dry-run results, the oldest ones are dropped first.
+type dryRunResults struct {
+	results []DryRunResult
+	mu      sync.Mutex
+}
+
+func newDryRunResults() *dryRunResults {
+	return &dryRunResults{
+		results: make([]DryRunResult, 0),
+	}
+}
+
+// Add keeps plugins of result running in dry-run mode and supporting the event, nothing is added if there is none.
+func (d *dryRunResults) Add(result *EventResult) {
+	out := DryRunResult{
+		Time:    time.Now(),
+		Event:   result.Event,
+		Action:  result.Action,
+		Repo:    result.Repo,
+		Plugins: make([]PluginResult, 0),
+	}
+	for _, v := range result.Plugins {
+		if v.DryRun && v.Supported {
+			out.Plugins = append(out.Plugins, v)
+		}
+	}
+	if len(out.Plugins) == 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.results = append(d.results, out)
+	if len(d.results) > maxDryRunResults {
+		d.results = append([]DryRunResult{}, d.results[len(d.results)-maxDryRunResults:]...)
+	}
+}
+
+// List returns results of the repo from newest to oldest, all repos are returned if repo is empty.
+func (d *dryRunResults) List(repo string) []DryRunResult {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]DryRunResult, 0)
+	for i := len(d.results) - 1; i >= 0; i-- {
+		if repo == "" || d.results[i].Repo == repo {
+			out = append(out, d.results[i])
+		}
+	}
+	return out
+}
+
+// dryRunSummary describes operations recorded by the plugin in one line, e.g. "AddLabel{...}, AddIssueComment{...}".
+func dryRunSummary(records []client.OperationRecord) string {
+	if len(records) == 0 {
+		return "no operations"
+	}
+	ops := make([]string, 0, len(records))
+	for _, record := range records {
+		ops = append(ops, fmt.Sprintf("%s%+v", record.Type, reflect.Indirect(reflect.ValueOf(record.Args)).Interface()))
+	}
+	return strings.Join(ops, ", ")
+}
@@ -4,13 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/fatedier/freebot/pkg/client"
 	"github.com/fatedier/freebot/pkg/client/githubapp"
 	"github.com/fatedier/freebot/pkg/event"
 	"github.com/fatedier/freebot/pkg/httputil"
 	"github.com/fatedier/freebot/pkg/log"
+	"github.com/fatedier/freebot/pkg/metrics"
 	"github.com/fatedier/freebot/plugin"
 
 	"github.com/google/go-github/github"
@@ -22,31 +25,233 @@ var (
 	ErrNoOwnerRepo    = httputil.NewHttpError(400, "event no owner and repo info")
 	ErrNoPlugins      = httputil.NewHttpError(400, "no correspond plugins")
 	ErrNoInstallation = httputil.NewHttpError(400, "no installation")
+
+	ErrInvalidSignature  = httputil.NewHttpError(401, "invalid signature")
+	ErrNoDeadLetterStore = httputil.NewHttpError(404, "dead letter store is not enabled")
 )
 
+type PluginError struct {
+	Plugin string
+	Err    error
+}
+
+// PluginsError records errors returned by plugins when handling one event.
+type PluginsError struct {
+	Errors []PluginError
+}
+
+func (e *PluginsError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, v := range e.Errors {
+		msgs = append(msgs, "["+v.Plugin+"] "+v.Err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Plugins returns names of plugins failed.
+func (e *PluginsError) Plugins() []string {
+	out := make([]string, 0, len(e.Errors))
+	for _, v := range e.Errors {
+		out = append(out, v.Plugin)
+	}
+	return out
+}
+
+// PluginResult records how a plugin handled an event,
+// PreconditionsPassed is always false if the plugin doesn't support the event.
+type PluginResult struct {
+	Plugin              string                   `json:"plugin"`
+	Supported           bool                     `json:"supported"`
+	PreconditionsPassed bool                     `json:"preconditions_passed"`
+	PreconditionError   string                   `json:"precondition_error,omitempty"`
+	Operations          []client.OperationRecord `json:"operations,omitempty"`
+	Error               string                   `json:"error,omitempty"`
+	// operations are recorded but not done
+	DryRun bool `json:"dry_run,omitempty"`
+
+	err error
+}
+
+// EventResult is the result of handling an event, it is the response body of webhook requests.
+type EventResult struct {
+	Event   string         `json:"event"`
+	Action  string         `json:"action,omitempty"`
+	Repo    string         `json:"repo,omitempty"`
+	Ignored string         `json:"ignored,omitempty"` // reason why the event is not handled by any plugin
+	Plugins []PluginResult `json:"plugins"`
+}
+
+// Err returns *PluginsError if some plugins failed, otherwise nil.
+func (r *EventResult) Err() error {
+	if r == nil {
+		return nil
+	}
+	pluginsErr := &PluginsError{}
+	for _, v := range r.Plugins {
+		if v.err != nil {
+			pluginsErr.Errors = append(pluginsErr.Errors, PluginError{
+				Plugin: v.Plugin,
+				Err:    v.err,
+			})
+		}
+	}
+	if len(pluginsErr.Errors) > 0 {
+		return pluginsErr
+	}
+	return nil
+}
+
+// PluginsCreator creates plugins for repos not found in plugins map,
+// it should return ErrNoPlugins if there is no conf for this repo.
+// ctx carries the installation of the event if freebot runs as a github app.
+type PluginsCreator func(ctx context.Context, owner, repo string) ([]plugin.Plugin, error)
+
 type EventHandler struct {
 	requireInstallation bool
 
 	// key is owner/repo
 	plugins map[string][]plugin.Plugin
+	creator PluginsCreator
+	// increased when plugins are updated, plugins created by the old creator are discarded
+	generation int
+
+	// events sent by these users are dropped, keys are lower case logins
+	ignoreSenders map[string]struct{}
+	// returns true if events sent by any bot should be dropped for the repo
+	ignoreBotSenders func(owner, repo string) bool
+
+	// recent events handled by dry-run plugins
+	dryRuns *dryRunResults
 
 	mu sync.RWMutex
 }
 
-func NewEventHandler(requireInstallation bool, plugins map[string][]plugin.Plugin) *EventHandler {
+func NewEventHandler(requireInstallation bool, plugins map[string][]plugin.Plugin, creator PluginsCreator) *EventHandler {
 	return &EventHandler{
 		requireInstallation: requireInstallation,
 		plugins:             plugins,
+		creator:             creator,
+		dryRuns:             newDryRunResults(),
 	}
 }
 
-func (eh *EventHandler) UpdatePlugins(plugins map[string][]plugin.Plugin) {
+// DryRunResults returns recent events handled by dry-run plugins of the repo, newest first.
+// Results of all repos are returned if repo is empty.
+func (eh *EventHandler) DryRunResults(repo string) []DryRunResult {
+	return eh.dryRuns.List(repo)
+}
+
+// SetRequireInstallation sets whether events must have the installation of github app.
+func (eh *EventHandler) SetRequireInstallation(requireInstallation bool) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	eh.requireInstallation = requireInstallation
+}
+
+func (eh *EventHandler) UpdatePlugins(plugins map[string][]plugin.Plugin, creator PluginsCreator) {
 	eh.mu.Lock()
 	defer eh.mu.Unlock()
 	eh.plugins = plugins
+	eh.creator = creator
+	eh.generation++
+}
+
+// RemovePlugins drops cached plugins of the repo, they are created by creator again when needed.
+func (eh *EventHandler) RemovePlugins(owner, repo string) {
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	delete(eh.plugins, owner+"/"+repo)
+	// plugins being created with the old conf are discarded
+	eh.generation++
+}
+
+// SetIgnoreSenders sets users whose events are dropped before handled by plugins,
+// ignoreBotSenders can be nil.
+func (eh *EventHandler) SetIgnoreSenders(senders []string, ignoreBotSenders func(owner, repo string) bool) {
+	ignoreSenders := make(map[string]struct{}, len(senders))
+	for _, v := range senders {
+		if v != "" {
+			ignoreSenders[strings.ToLower(v)] = struct{}{}
+		}
+	}
+
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	eh.ignoreSenders = ignoreSenders
+	eh.ignoreBotSenders = ignoreBotSenders
+}
+
+// ignoredSender returns the reason if events sent by sender should be dropped.
+func (eh *EventHandler) ignoredSender(owner, repo string, sender *github.User) (reason string, ignored bool) {
+	if sender == nil {
+		return "", false
+	}
+
+	eh.mu.RLock()
+	_, ok := eh.ignoreSenders[strings.ToLower(sender.GetLogin())]
+	ignoreBotSenders := eh.ignoreBotSenders
+	eh.mu.RUnlock()
+
+	if ok {
+		return fmt.Sprintf("sender [%s] is ignored", sender.GetLogin()), true
+	}
+	if sender.GetType() == "Bot" && ignoreBotSenders != nil && ignoreBotSenders(owner, repo) {
+		return fmt.Sprintf("sender [%s] is a bot", sender.GetLogin()), true
+	}
+	return "", false
 }
 
-func (eh *EventHandler) HandleEvent(ctx context.Context, evType string, content string) (err error) {
+// AllPlugins returns a copy of plugins created, key is owner/repo.
+func (eh *EventHandler) AllPlugins() map[string][]plugin.Plugin {
+	eh.mu.RLock()
+	defer eh.mu.RUnlock()
+
+	out := make(map[string][]plugin.Plugin, len(eh.plugins))
+	for k, v := range eh.plugins {
+		out[k] = v
+	}
+	return out
+}
+
+// GetPlugins returns plugins of the repo, they are created by creator and cached if not exist.
+func (eh *EventHandler) GetPlugins(ctx context.Context, owner, repo string) ([]plugin.Plugin, error) {
+	key := owner + "/" + repo
+	eh.mu.RLock()
+	plugins, ok := eh.plugins[key]
+	creator := eh.creator
+	generation := eh.generation
+	eh.mu.RUnlock()
+	if ok {
+		return plugins, nil
+	}
+	if creator == nil {
+		return nil, ErrNoPlugins
+	}
+
+	plugins, err := creator(ctx, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	eh.mu.Lock()
+	defer eh.mu.Unlock()
+	if exist, ok := eh.plugins[key]; ok {
+		return exist, nil
+	}
+	if generation == eh.generation {
+		eh.plugins[key] = plugins
+	}
+	return plugins, nil
+}
+
+func (eh *EventHandler) HandleEvent(ctx context.Context, evType string, content string) (*EventResult, error) {
+	return eh.HandleEventWithPlugins(ctx, evType, content, nil)
+}
+
+// HandleEventWithPlugins only runs plugins in onlyPlugins if it is not empty.
+// Returned error is *PluginsError if some plugins failed, the result is not nil in this case.
+func (eh *EventHandler) HandleEventWithPlugins(ctx context.Context, evType string, content string,
+	onlyPlugins []string) (result *EventResult, err error) {
 	var (
 		payload interface{}
 		owner   string
@@ -55,6 +260,10 @@ func (eh *EventHandler) HandleEvent(ctx context.Context, evType string, content
 
 	// parse content
 	switch evType {
+	case event.EvIssues:
+		v := &github.IssuesEvent{}
+		err = json.Unmarshal([]byte(content), &v)
+		payload = v
 	case event.EvIssueComment:
 		v := &github.IssueCommentEvent{}
 		err = json.Unmarshal([]byte(content), &v)
@@ -80,13 +289,13 @@ func (eh *EventHandler) HandleEvent(ctx context.Context, evType string, content
 		err = json.Unmarshal([]byte(content), &v)
 		payload = v
 	case event.EvPing:
-		return nil
+		return &EventResult{Event: evType, Plugins: make([]PluginResult, 0)}, nil
 	default:
-		return ErrNoSupportEvent
+		return nil, ErrNoSupportEvent
 	}
 
 	if err != nil {
-		return ErrEventPayload
+		return nil, ErrEventPayload
 	}
 
 	// get owner and repo name
@@ -94,48 +303,127 @@ func (eh *EventHandler) HandleEvent(ctx context.Context, evType string, content
 		owner = v.GetRepo().GetOwner().GetLogin()
 		repo = v.GetRepo().GetName()
 	} else {
-		return ErrNoOwnerRepo
+		return nil, ErrNoOwnerRepo
+	}
+
+	if v, ok := payload.(client.GetSenderInterface); ok {
+		if reason, ignored := eh.ignoredSender(owner, repo, v.GetSender()); ignored {
+			log.Debug("[%s/%s] event [%s] ignored: %s", owner, repo, evType, reason)
+			result = &EventResult{
+				Event:   evType,
+				Repo:    owner + "/" + repo,
+				Ignored: reason,
+				Plugins: make([]PluginResult, 0),
+			}
+			if v, ok := payload.(client.GetActionInterface); ok {
+				result.Action = v.GetAction()
+			}
+			return result, nil
+		}
 	}
 
-	if eh.requireInstallation {
+	eh.mu.RLock()
+	requireInstallation := eh.requireInstallation
+	eh.mu.RUnlock()
+	if requireInstallation {
 		if v, ok := payload.(client.GetInstallationInterface); ok && v.GetInstallation() != nil && v.GetInstallation().ID != nil {
 			ctx = githubapp.WithInstallID(ctx, int(*v.GetInstallation().ID))
 		} else {
-			return ErrNoInstallation
+			return nil, ErrNoInstallation
 		}
 	}
 
 	// get plugins
-	eh.mu.RLock()
-	plugins, ok := eh.plugins[owner+"/"+repo]
-	eh.mu.RUnlock()
-	if !ok {
-		return ErrNoPlugins
+	plugins, err := eh.GetPlugins(ctx, owner, repo)
+	if err != nil {
+		return nil, err
 	}
 
 	// handle event by plugins
-	var (
-		notSupport bool
-		partialErr error
-	)
 	object := client.NewObject(payload)
+	result = &EventResult{
+		Event:   evType,
+		Repo:    owner + "/" + repo,
+		Plugins: make([]PluginResult, 0, len(plugins)),
+	}
+	if action, ok := object.Action(); ok {
+		result.Action = action
+	}
 	for _, p := range plugins {
-		notSupport, partialErr = p.HandleEvent(&event.EventContext{
-			Ctx:    ctx,
+		if len(onlyPlugins) > 0 && !stringContains(onlyPlugins, p.Name()) {
+			continue
+		}
+
+		recorder := client.NewOperationRecorder()
+		start := time.Now()
+		notSupport, partialErr := p.HandleEvent(&event.EventContext{
+			Ctx:    client.WithOperationRecorder(ctx, recorder),
 			Type:   evType,
 			Owner:  owner,
 			Repo:   repo,
 			Object: object,
 		})
+		metrics.PluginHandleDuration.Observe(time.Since(start).Seconds(),
+			owner+"/"+repo, p.Name(), pluginOutcome(notSupport, partialErr))
 		if notSupport {
 			log.Debug("[%s/%s] plugin [%s] not support", owner, repo, p.Name())
+			result.Plugins = append(result.Plugins, PluginResult{Plugin: p.Name()})
 			continue
 		}
 
 		log.Info("[%s/%s] plugin: [%s] event: [%v]", owner, repo, p.Name(), evType)
-		if partialErr != nil {
-			err = fmt.Errorf("%v;[%s] %v", err, p.Name(), partialErr)
+		pluginResult := PluginResult{
+			Plugin:              p.Name(),
+			Supported:           true,
+			PreconditionsPassed: true,
+			Operations:          recorder.Records(),
+		}
+		if v, ok := p.(plugin.DryRunInterface); ok && v.IsDryRun() {
+			pluginResult.DryRun = true
+			log.Info("[%s/%s] plugin [%s] dry-run event [%s]: %s", owner, repo, p.Name(), evType,
+				dryRunSummary(pluginResult.Operations))
+		}
+		if plugin.IsPreconditionError(partialErr) {
+			// not meeting preconditions is expected, e.g. commands from users without permission
+			log.Info("[%s/%s] plugin [%s] preconditions not satisfied: %v", owner, repo, p.Name(), partialErr)
+			pluginResult.PreconditionsPassed = false
+			pluginResult.PreconditionError = partialErr.Error()
+		} else if partialErr != nil {
+			pluginResult.Error = partialErr.Error()
+			pluginResult.err = partialErr
+		}
+		result.Plugins = append(result.Plugins, pluginResult)
+
+		// changes of dry-run plugins must not affect plugins running for real
+		for _, record := range pluginResult.Operations {
+			if record.Error == "" && !record.DryRun {
+				object.ApplyLabelOperation(record.Args)
+			}
+		}
+	}
+	eh.dryRuns.Add(result)
+
+	return result, result.Err()
+}
+
+func pluginOutcome(notSupport bool, err error) string {
+	switch {
+	case notSupport:
+		return metrics.OutcomeNotSupported
+	case err == nil:
+		return metrics.OutcomeOK
+	case plugin.IsPreconditionError(err):
+		return metrics.OutcomePreconditionFailed
+	default:
+		return metrics.OutcomeError
+	}
+}
+
+func stringContains(strs []string, s string) bool {
+	for _, v := range strs {
+		if v == s {
+			return true
 		}
 	}
-	return err
+	return false
 }
@@ -13,4 +13,5 @@ require (
 	github.com/spf13/pflag v1.0.3 // indirect
 	golang.org/x/net v0.0.0-20190225153610-fe579d43d832 // indirect
 	golang.org/x/oauth2 v0.0.0-20190130055435-99b60b757ec1
+	gopkg.in/yaml.v3 v3.0.1
 )
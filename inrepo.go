@@ -0,0 +1,336 @@
+package freebot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fatedier/freebot/pkg/client"
+	"github.com/fatedier/freebot/pkg/config"
+	"github.com/fatedier/freebot/pkg/event"
+	"github.com/fatedier/freebot/pkg/log"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	// host conf wins if both confs define the same alias, role, label or plugin
+	InRepoConfPrecedenceHost = "host"
+	// in-repo conf wins if both confs define the same alias, role, label or plugin
+	InRepoConfPrecedenceRepo = "repo"
+)
+
+// InRepoConfPaths are files read from the default branch of repos, the first one found is used.
+var InRepoConfPaths = []string{
+	".github/freebot.json",
+	".github/freebot.yaml",
+	".github/freebot.yml",
+}
+
+// DefaultInRepoConfDeniedPlugins can't be enabled or configured by in-repo conf unless they are listed
+// in in_repo_conf_plugins, they run commands on the freebot host or send requests to any url.
+var DefaultInRepoConfDeniedPlugins = []string{"trigger", "remote"}
+
+type inRepoConfEntry struct {
+	// nil if no conf file is found or the file is invalid
+	conf *RepoConf
+}
+
+// inRepoConfCache caches confs read from repos until they are invalidated by push events.
+type inRepoConfCache struct {
+	entries map[string]*inRepoConfEntry
+	mu      sync.Mutex
+}
+
+func newInRepoConfCache() *inRepoConfCache {
+	return &inRepoConfCache{
+		entries: make(map[string]*inRepoConfEntry),
+	}
+}
+
+func (c *inRepoConfCache) Get(key string) (*inRepoConfEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *inRepoConfCache) Set(key string, entry *inRepoConfEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+}
+
+func (c *inRepoConfCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// loadInRepoConf returns the conf in the repo, nil is returned if the repo has no valid conf file.
+// Invalid files are cached as not found so they are not fetched again until changed.
+func (svc *Service) loadInRepoConf(ctx context.Context, cli client.ClientInterface, owner, repo string) (*RepoConf, error) {
+	key := owner + "/" + repo
+	if entry, ok := svc.inRepoConfs.Get(key); ok {
+		return entry.conf, nil
+	}
+
+	entry := &inRepoConfEntry{}
+	for _, confPath := range InRepoConfPaths {
+		content, err := cli.GetFileContent(ctx, owner, repo, confPath)
+		if err == client.ErrFileNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("get in-repo conf [%s] of repo [%s] error: %v", confPath, key, err)
+		}
+
+		conf := RepoConf{}
+		if err = config.Unmarshal(confPath, content, &conf); err != nil {
+			log.Error("repo [%s] parse in-repo conf [%s] error: %v, it is ignored", key, confPath, err)
+			break
+		}
+		entry.conf = &conf
+		log.Info("repo [%s] in-repo conf [%s] loaded", key, confPath)
+		break
+	}
+	svc.inRepoConfs.Set(key, entry)
+	return entry.conf, nil
+}
+
+// InvalidateInRepoConf drops the cached in-repo conf and plugins of the repo,
+// they are created again when the next event of this repo arrives.
+func (svc *Service) InvalidateInRepoConf(owner, repo string) {
+	svc.inRepoConfs.Remove(owner + "/" + repo)
+	svc.eventHandler.RemovePlugins(owner, repo)
+}
+
+// handlePushEvent invalidates the in-repo conf if a push to the default branch changes it.
+func (svc *Service) handlePushEvent(content []byte) (*EventResult, error) {
+	ev := &github.PushEvent{}
+	if err := json.Unmarshal(content, ev); err != nil {
+		return nil, ErrEventPayload
+	}
+	// owner in push events has no login field
+	arrs := strings.Split(ev.GetRepo().GetFullName(), "/")
+	if len(arrs) != 2 || arrs[0] == "" || arrs[1] == "" {
+		return nil, ErrNoOwnerRepo
+	}
+	owner, repo := arrs[0], arrs[1]
+
+	result := &EventResult{
+		Event:   event.EvPush,
+		Repo:    owner + "/" + repo,
+		Plugins: make([]PluginResult, 0),
+	}
+	if !svc.currentEnv().inRepoConf {
+		result.Ignored = "in-repo conf is not enabled"
+		return result, nil
+	}
+	if ev.GetRef() != "refs/heads/"+ev.GetRepo().GetDefaultBranch() {
+		result.Ignored = "not the default branch"
+		return result, nil
+	}
+	if !pushTouchesInRepoConf(ev) {
+		result.Ignored = "in-repo conf is not changed"
+		return result, nil
+	}
+
+	log.Info("repo [%s] in-repo conf changed in push [%s], reload it", result.Repo, ev.GetAfter())
+	svc.InvalidateInRepoConf(owner, repo)
+	return result, nil
+}
+
+func pushTouchesInRepoConf(ev *github.PushEvent) bool {
+	commits := ev.Commits
+	if ev.HeadCommit != nil {
+		commits = append(commits, *ev.HeadCommit)
+	}
+	for _, commit := range commits {
+		for _, files := range [][]string{commit.Added, commit.Removed, commit.Modified} {
+			for _, file := range files {
+				if stringContains(InRepoConfPaths, file) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// mergeInRepoConf merges the in-repo conf with the host conf, entries in maps are overridden by the
+// conf with higher precedence. Webhook secret and options about senders and locks only come from host.
+// If lock_preconditions is set in host conf, roles, label roles and plugins defined by host
+// always use host values and in-repo conf can't add plugins or label roles, so that it can't loosen preconditions.
+func mergeInRepoConf(host RepoConf, inRepo RepoConf, precedence string) RepoConf {
+	low, high := inRepo, host
+	if precedence == InRepoConfPrecedenceRepo {
+		low, high = host, inRepo
+	}
+
+	out := RepoConf{
+		Alias: config.AliasOptions{
+			Cmds:   mergeStringMap(low.Alias.Cmds, high.Alias.Cmds),
+			Labels: mergeStringMap(low.Alias.Labels, high.Alias.Labels),
+			Users:  mergeStringMap(low.Alias.Users, high.Alias.Users),
+		},
+		Roles:      make(config.RoleOptions),
+		LabelRoles: make(config.LabelRoles),
+		Plugins:    make(map[string]PluginConfig),
+
+		WebhookSecret:     host.WebhookSecret,
+		IgnoreBotSenders:  host.IgnoreBotSenders,
+		LockPreconditions: host.LockPreconditions,
+		// in-repo conf can enable dry-run but can't disable it
+		DryRun:   host.DryRun || inRepo.DryRun,
+		Feedback: high.Feedback,
+	}
+	if out.Feedback.Mode == "" {
+		out.Feedback = low.Feedback
+	}
+	for _, conf := range []RepoConf{low, high} {
+		for k, v := range conf.Roles {
+			out.Roles[k] = v
+		}
+		for k, v := range conf.LabelRoles {
+			out.LabelRoles[k] = v
+		}
+		for k, v := range conf.Plugins {
+			out.Plugins[k] = v
+		}
+	}
+
+	if host.LockPreconditions {
+		out.Alias = config.AliasOptions{
+			Cmds:   mergeStringMap(inRepo.Alias.Cmds, host.Alias.Cmds),
+			Labels: mergeStringMap(inRepo.Alias.Labels, host.Alias.Labels),
+			Users:  mergeStringMap(inRepo.Alias.Users, host.Alias.Users),
+		}
+		for k, v := range host.Roles {
+			out.Roles[k] = v
+		}
+		for k, v := range host.LabelRoles {
+			out.LabelRoles[k] = v
+		}
+		for name, hostConf := range host.Plugins {
+			// disabling the plugin or changing its priority is still allowed
+			pluginConf := out.Plugins[name]
+			pluginConf.Preconditions = hostConf.Preconditions
+			pluginConf.Extra = hostConf.Extra
+			pluginConf.DryRun = pluginConf.DryRun || hostConf.DryRun
+			out.Plugins[name] = pluginConf
+		}
+		// plugins and label roles not defined by host would bring their own preconditions
+		for name := range out.Plugins {
+			if _, ok := host.Plugins[name]; !ok {
+				delete(out.Plugins, name)
+			}
+		}
+		for label := range out.LabelRoles {
+			if _, ok := host.LabelRoles[label]; !ok {
+				delete(out.LabelRoles, label)
+			}
+		}
+	}
+	return out
+}
+
+func mergeStringMap(low map[string]string, high map[string]string) map[string]string {
+	out := make(map[string]string, len(low)+len(high))
+	for k, v := range low {
+		out[k] = v
+	}
+	for k, v := range high {
+		out[k] = v
+	}
+	return out
+}
+
+// effectiveRepoConf returns the conf used to create plugins of the repo, in-repo conf is merged if enabled.
+func (svc *Service) effectiveRepoConf(ctx context.Context, env pluginsEnv, repoConfs map[string]RepoConf,
+	owner, repo string) (repoConf RepoConf, confKey string, ok bool, err error) {
+	repoConf, confKey, ok = matchRepoConf(repoConfs, owner+"/"+repo)
+	if !env.inRepoConf {
+		return
+	}
+
+	inRepo, err := svc.loadInRepoConf(ctx, env.cli, owner, repo)
+	if err != nil || inRepo == nil {
+		return
+	}
+	return mergeEffectiveRepoConf(env, owner, repo, repoConf, confKey, ok, *inRepo)
+}
+
+// mergeEffectiveRepoConf merges the in-repo conf on the host conf matched,
+// defaults of the owner are used as host conf if the repo is not configured in host.
+func mergeEffectiveRepoConf(env pluginsEnv, owner, repo string, repoConf RepoConf, confKey string, ok bool,
+	inRepo RepoConf) (RepoConf, string, bool, error) {
+	resolved, err := env.resolver.ResolveTemplates(inRepo)
+	if err != nil {
+		log.Error("repo [%s/%s] resolve in-repo conf error: %v, it is ignored", owner, repo, err)
+		return repoConf, confKey, ok, nil
+	}
+
+	resolved.Plugins = filterInRepoPlugins(env, owner, repo, resolved.Plugins)
+
+	if !ok {
+		orgDefault, hasDefault, err := env.resolver.OrgDefault(owner)
+		if err != nil {
+			return repoConf, confKey, false, err
+		}
+		if !hasDefault {
+			if !env.inRepoConfUnconfiguredRepos {
+				log.Debug("repo [%s/%s] has no host conf, in-repo conf is ignored", owner, repo)
+				return repoConf, confKey, false, nil
+			}
+			return mergeInRepoConf(RepoConf{}, resolved, InRepoConfPrecedenceRepo), "in-repo", true, nil
+		}
+		repoConf, confKey = orgDefault, fmt.Sprintf("org_defaults[%s]", owner)
+	}
+	return mergeInRepoConf(repoConf, resolved, env.inRepoConfPrecedence), confKey + " + in-repo", true, nil
+}
+
+// filterInRepoPlugins drops plugins which in-repo conf is not allowed to enable or configure,
+// host conf of these plugins is still used.
+func filterInRepoPlugins(env pluginsEnv, owner, repo string, plugins map[string]PluginConfig) map[string]PluginConfig {
+	out := make(map[string]PluginConfig, len(plugins))
+	for name, conf := range plugins {
+		if !env.inRepoPluginAllowed(name) {
+			log.Warn("repo [%s/%s] in-repo conf of plugin [%s] is not allowed, it is ignored", owner, repo, name)
+			continue
+		}
+		out[name] = conf
+	}
+	return out
+}
+
+func (env pluginsEnv) inRepoPluginAllowed(name string) bool {
+	if env.inRepoConfPlugins != nil {
+		return stringContains(env.inRepoConfPlugins, name)
+	}
+	return !stringContains(DefaultInRepoConfDeniedPlugins, name)
+}
+
+// ResolvedRepoConf returns the conf of the repo with templates, org defaults and the cached in-repo conf resolved,
+// key describes where the conf comes from. In-repo conf is not fetched if it is not cached.
+func (svc *Service) ResolvedRepoConf(owner, repo string) (conf RepoConf, key string, ok bool, err error) {
+	svc.mu.RLock()
+	env := svc.env
+	repoConfs := svc.repoConfs
+	svc.mu.RUnlock()
+
+	conf, key, ok = matchRepoConf(repoConfs, owner+"/"+repo)
+	if env.inRepoConf {
+		if entry, cached := svc.inRepoConfs.Get(owner + "/" + repo); cached && entry.conf != nil {
+			return mergeEffectiveRepoConf(env, owner, repo, conf, key, ok, *entry.conf)
+		}
+	}
+	if ok {
+		return
+	}
+
+	conf, ok, err = env.resolver.OrgDefault(owner)
+	return conf, fmt.Sprintf("org_defaults[%s]", owner), ok, err
+}
@@ -0,0 +1,89 @@
+package freebot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fatedier/freebot/pkg/config"
+)
+
+func TestMergeInRepoConfLockPreconditions(t *testing.T) {
+	ownerOnly := []config.Precondition{{RequiredRoles: []string{"owner"}}}
+	priority := 5
+	host := RepoConf{
+		LockPreconditions: true,
+		Roles:             config.RoleOptions{"owner": {"alice"}},
+		LabelRoles: config.LabelRoles{
+			"module/core": {"owner": {"alice"}},
+		},
+		Plugins: map[string]PluginConfig{
+			"lgtm":   {Preconditions: ownerOnly},
+			"status": {Preconditions: ownerOnly},
+		},
+	}
+	inRepo := RepoConf{
+		Roles: config.RoleOptions{"owner": {"mallory"}, "qa": {"bob"}},
+		LabelRoles: config.LabelRoles{
+			"module/core": {"owner": {"mallory"}},
+			"module/new":  {"owner": {"mallory"}},
+		},
+		Plugins: map[string]PluginConfig{
+			"lgtm":   {Preconditions: []config.Precondition{}},
+			"status": {Disable: true, Priority: &priority},
+			"merge":  {Preconditions: []config.Precondition{}},
+		},
+	}
+
+	for _, precedence := range []string{InRepoConfPrecedenceHost, InRepoConfPrecedenceRepo} {
+		out := mergeInRepoConf(host, inRepo, precedence)
+
+		if _, ok := out.Plugins["merge"]; ok {
+			t.Errorf("[%s] plugin merge not defined by host is added", precedence)
+		}
+		if !reflect.DeepEqual(out.Plugins["lgtm"].Preconditions, ownerOnly) {
+			t.Errorf("[%s] preconditions of lgtm = %v, want host values", precedence, out.Plugins["lgtm"].Preconditions)
+		}
+		if status := out.Plugins["status"]; !reflect.DeepEqual(status.Preconditions, ownerOnly) {
+			t.Errorf("[%s] preconditions of status = %v, want host values", precedence, status.Preconditions)
+		}
+		// disabling plugins and changing their priority is still allowed if in-repo conf wins
+		if status := out.Plugins["status"]; precedence == InRepoConfPrecedenceRepo &&
+			(!status.Disable || status.Priority == nil || *status.Priority != priority) {
+			t.Errorf("[%s] status = %+v, want disabled with priority %d", precedence, status, priority)
+		}
+
+		if _, ok := out.LabelRoles["module/new"]; ok {
+			t.Errorf("[%s] label role module/new not defined by host is added", precedence)
+		}
+		if !reflect.DeepEqual(out.LabelRoles["module/core"], host.LabelRoles["module/core"]) {
+			t.Errorf("[%s] label role module/core = %v, want host values", precedence, out.LabelRoles["module/core"])
+		}
+
+		if !reflect.DeepEqual(out.Roles["owner"], []string{"alice"}) {
+			t.Errorf("[%s] role owner = %v, want host values", precedence, out.Roles["owner"])
+		}
+		if !reflect.DeepEqual(out.Roles["qa"], []string{"bob"}) {
+			t.Errorf("[%s] new role qa = %v, want in-repo values", precedence, out.Roles["qa"])
+		}
+	}
+}
+
+func TestMergeInRepoConfWithoutLock(t *testing.T) {
+	host := RepoConf{
+		Plugins: map[string]PluginConfig{"lgtm": {}},
+	}
+	inRepo := RepoConf{
+		LabelRoles: config.LabelRoles{"module/new": {"owner": {"bob"}}},
+		Plugins:    map[string]PluginConfig{"merge": {}},
+	}
+
+	out := mergeInRepoConf(host, inRepo, InRepoConfPrecedenceHost)
+	for _, name := range []string{"lgtm", "merge"} {
+		if _, ok := out.Plugins[name]; !ok {
+			t.Errorf("plugin %s is missing", name)
+		}
+	}
+	if _, ok := out.LabelRoles["module/new"]; !ok {
+		t.Error("label role module/new is missing")
+	}
+}
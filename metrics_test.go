@@ -0,0 +1,122 @@
+package freebot
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatedier/freebot/pkg/client"
+	"github.com/fatedier/freebot/pkg/dedup"
+	"github.com/fatedier/freebot/pkg/event"
+	"github.com/fatedier/freebot/plugin"
+
+	"github.com/google/go-github/github"
+)
+
+// fakePlugin returns the fixed result, it adds a label before returning if cli is set.
+type fakePlugin struct {
+	name       string
+	notSupport bool
+	err        error
+	cli        client.ClientInterface
+}
+
+func (p *fakePlugin) Name() string {
+	return p.name
+}
+
+func (p *fakePlugin) HandleEvent(ctx *event.EventContext) (bool, error) {
+	if p.cli != nil {
+		if err := p.cli.DoOperation(ctx.Ctx, &client.AddLabelOperation{
+			Owner:  ctx.Owner,
+			Repo:   ctx.Repo,
+			Number: 1,
+			Labels: []string{"bug"},
+		}); err != nil {
+			return false, err
+		}
+	}
+	return p.notSupport, p.err
+}
+
+func TestMetricsScrape(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4321")
+		w.Write([]byte(`[{"name": "bug"}]`))
+	}))
+	defer api.Close()
+	u, err := url.Parse(api.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gh := github.NewClient(&http.Client{Transport: client.NewMetricsTransport(nil)})
+	gh.BaseURL = u
+	cli := client.NewGithubClient(gh)
+
+	// quotes and backslashes in the repo name must be escaped in label values
+	repo := `metrics"test\repo`
+	plugins := []plugin.Plugin{
+		&fakePlugin{name: "p-ok", cli: cli},
+		&fakePlugin{name: "p-error", err: errors.New("failed")},
+		&fakePlugin{name: "p-precondition", err: &plugin.PreconditionError{Err: errors.New("not allowed")}},
+		&fakePlugin{name: "p-not-supported", notSupport: true},
+	}
+	svc := &Service{
+		dedup:        dedup.NewMemoryStore(time.Hour, 100),
+		repoConfs:    map[string]RepoConf{"org/" + repo: {}},
+		eventHandler: NewEventHandler(false, map[string][]plugin.Plugin{"org/" + repo: plugins}, nil),
+	}
+
+	payload := `{"action": "opened", "issue": {"number": 1},
+		"repository": {"full_name": "org/metrics\"test\\repo", "name": "metrics\"test\\repo", "owner": {"login": "org"}},
+		"sender": {"login": "alice"}}`
+	r := httptest.NewRequest("POST", "/", strings.NewReader(payload))
+	r.Header.Set("X-Github-Event", event.EvIssues)
+	r.Header.Set("X-GitHub-Delivery", "metrics-test")
+	w := httptest.NewRecorder()
+	svc.Handler(w, r)
+	// p-error failed
+	if w.Code != 500 {
+		t.Fatalf("handle event status %d, want 500, body: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	svc.AdminHandler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != 200 {
+		t.Fatalf("scrape status %d, want 200", w.Code)
+	}
+	body, _ := ioutil.ReadAll(w.Body)
+	lines := strings.Split(string(body), "\n")
+
+	escaped := `org/metrics\"test\\repo`
+	want := []string{
+		`freebot_events_received_total{event="issues",action="opened",repo="` + escaped + `"} 1`,
+		`freebot_plugin_handle_duration_seconds_bucket{repo="` + escaped + `",plugin="p-ok",outcome="ok",le="+Inf"} 1`,
+		`freebot_plugin_handle_duration_seconds_count{repo="` + escaped + `",plugin="p-ok",outcome="ok"} 1`,
+		`freebot_plugin_handle_duration_seconds_count{repo="` + escaped + `",plugin="p-error",outcome="error"} 1`,
+		`freebot_plugin_handle_duration_seconds_count{repo="` + escaped + `",plugin="p-precondition",outcome="precondition_failed"} 1`,
+		`freebot_plugin_handle_duration_seconds_count{repo="` + escaped + `",plugin="p-not-supported",outcome="not_supported"} 1`,
+		`freebot_github_api_calls_total{operation="AddLabel",code="200"} 1`,
+		`freebot_github_rate_limit_remaining 4321`,
+		`# TYPE freebot_plugin_handle_duration_seconds histogram`,
+	}
+	for _, line := range want {
+		if !containsLine(lines, line) {
+			t.Errorf("line %q not found in metrics:\n%s", line, body)
+		}
+	}
+}
+
+func containsLine(lines []string, line string) bool {
+	for _, v := range lines {
+		if v == line {
+			return true
+		}
+	}
+	return false
+}
@@ -13,6 +13,8 @@ type ClientInterface interface {
 	ListPullRequestBySHA(ctx context.Context, owner, repo, sha string) ([]PullRequest, error)
 	ListFilesByPullRequest(ctx context.Context, owner, repo string, number int) ([]string, error)
 	ListLabels(ctx context.Context, owner, repo string, number int) ([]string, error)
+	GetFileContent(ctx context.Context, owner, repo, path string) ([]byte, error)
+	ListTeamMembers(ctx context.Context, org, slug string) ([]string, error)
 }
 
 var _ ClientInterface = &githubClient{}
@@ -28,6 +30,7 @@ func NewGithubClient(client *github.Client) ClientInterface {
 }
 
 func (cli *githubClient) DoOperation(ctx context.Context, op interface{}) (err error) {
+	ctx = WithOperation(ctx, OperationName(op))
 	switch v := op.(type) {
 	case *ReplaceLabelOperation:
 		err = cli.doReplaceLabelOperation(ctx, v)
@@ -51,8 +54,14 @@ func (cli *githubClient) DoOperation(ctx context.Context, op interface{}) (err e
 		err = cli.doRemoveLabelOperation(ctx, v)
 	case *AddIssueCommentOperation:
 		err = cli.doAddIssueCommentOperation(ctx, v)
+	case *AddReactionOperation:
+		err = cli.doAddReactionOperation(ctx, v)
 	default:
 		err = fmt.Errorf("no support operation")
 	}
+
+	if r, ok := OperationRecorderFromContext(ctx); ok {
+		r.Record(op, err)
+	}
 	return
 }
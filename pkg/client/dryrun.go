@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+)
+
+var _ ClientInterface = &DryRunClient{}
+
+// DryRunClient records operations instead of doing them, read calls are sent to the wrapped client.
+type DryRunClient struct {
+	ClientInterface
+}
+
+func NewDryRunClient(cli ClientInterface) *DryRunClient {
+	return &DryRunClient{
+		ClientInterface: cli,
+	}
+}
+
+// DoOperation records op as a dry-run operation with the recorder in ctx, github is not called.
+func (cli *DryRunClient) DoOperation(ctx context.Context, op interface{}) error {
+	if r, ok := OperationRecorderFromContext(ctx); ok {
+		r.RecordDryRun(op)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
 	"sync"
 
 	"github.com/bradleyfalzon/ghinstallation"
@@ -24,6 +25,7 @@ func WithInstallID(ctx context.Context, id int) context.Context {
 type GithubAppInstallTransport struct {
 	tr                http.RoundTripper
 	appID             int
+	appSlug           string
 	privateKey        []byte
 	installTransports map[int]http.RoundTripper
 	mu                sync.RWMutex
@@ -43,6 +45,18 @@ func NewGithubAppInstallTransport(tr http.RoundTripper, appID int, privateKeyFil
 		return nil, err
 	}
 	githubCli := github.NewClient(&http.Client{Transport: appTr})
+	// github.App has no slug field in this version of go-github
+	req, err := githubCli.NewRequest("GET", "app", nil)
+	if err != nil {
+		return nil, err
+	}
+	app := struct {
+		Slug string `json:"slug"`
+	}{}
+	if _, err = githubCli.Do(context.Background(), req, &app); err != nil {
+		return nil, fmt.Errorf("get github app error: %v", err)
+	}
+
 	installs, _, err := githubCli.Apps.ListInstallations(context.Background(), &github.ListOptions{
 		Page:    1,
 		PerPage: 100,
@@ -54,6 +68,7 @@ func NewGithubAppInstallTransport(tr http.RoundTripper, appID int, privateKeyFil
 	out := &GithubAppInstallTransport{
 		tr:                tr,
 		appID:             appID,
+		appSlug:           app.Slug,
 		privateKey:        privateKey,
 		installTransports: make(map[int]http.RoundTripper),
 		doingUpdateID:     make(map[int]struct{}),
@@ -70,6 +85,24 @@ func NewGithubAppInstallTransport(tr http.RoundTripper, appID int, privateKeyFil
 	return out, nil
 }
 
+// BotLogin returns the login of the bot user acting as this app, comments created by the app are sent by it.
+func (tr *GithubAppInstallTransport) BotLogin() string {
+	return tr.appSlug + "[bot]"
+}
+
+// InstallIDs returns ids of installations whose transport has been initialized.
+func (tr *GithubAppInstallTransport) InstallIDs() []int {
+	tr.mu.RLock()
+	defer tr.mu.RUnlock()
+
+	out := make([]int, 0, len(tr.installTransports))
+	for id := range tr.installTransports {
+		out = append(out, id)
+	}
+	sort.Ints(out)
+	return out
+}
+
 func (tr *GithubAppInstallTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	installID, ok := req.Context().Value(installIDKey).(int)
 	if !ok {
@@ -9,6 +9,7 @@ import (
 )
 
 func (cli *githubClient) ListLabels(ctx context.Context, owner, repo string, number int) ([]string, error) {
+	ctx = WithOperation(ctx, "ListLabels")
 	labelNames := make([]string, 0)
 	labels, _, err := cli.client.Issues.ListLabelsByIssue(ctx, owner, repo, number, &github.ListOptions{
 		PerPage: 100,
@@ -115,7 +116,7 @@ type CloseOperation struct {
 	Owner  string
 	Repo   string
 	Number int
-	Object *Object // can get issue or pr info from payload
+	Object *Object `json:"-"` // can get issue or pr info from payload
 }
 
 func (cli *githubClient) doCloseOperation(ctx context.Context, op *CloseOperation) error {
@@ -142,7 +143,7 @@ type ReopenOperation struct {
 	Owner  string
 	Repo   string
 	Number int
-	Object *Object // can get issue or pr info from payload
+	Object *Object `json:"-"` // can get issue or pr info from payload
 }
 
 func (cli *githubClient) doReopenOperation(ctx context.Context, op *ReopenOperation) error {
@@ -178,3 +179,22 @@ func (cli *githubClient) doAddIssueCommentOperation(ctx context.Context, op *Add
 	})
 	return err
 }
+
+// AddReactionOperation adds a reaction to an issue comment, or a pull request review comment if ReviewComment is true.
+type AddReactionOperation struct {
+	Owner         string
+	Repo          string
+	CommentID     int64
+	ReviewComment bool
+	// one of +1, -1, laugh, confused, heart, hooray
+	Content string
+}
+
+func (cli *githubClient) doAddReactionOperation(ctx context.Context, op *AddReactionOperation) (err error) {
+	if op.ReviewComment {
+		_, _, err = cli.client.Reactions.CreatePullRequestCommentReaction(ctx, op.Owner, op.Repo, op.CommentID, op.Content)
+	} else {
+		_, _, err = cli.client.Reactions.CreateIssueCommentReaction(ctx, op.Owner, op.Repo, op.CommentID, op.Content)
+	}
+	return
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/fatedier/freebot/pkg/metrics"
+)
+
+type operationKeyType struct{}
+
+var operationKey = operationKeyType{}
+
+// WithOperation records the operation name in ctx, api calls sent with ctx are counted by it.
+func WithOperation(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, operationKey, name)
+}
+
+// OperationName returns name of the operation type, e.g. ReplaceLabel for *ReplaceLabelOperation.
+func OperationName(op interface{}) string {
+	t := reflect.TypeOf(op)
+	if t == nil {
+		return "unknown"
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return strings.TrimSuffix(t.Name(), "Operation")
+}
+
+// MetricsTransport counts github api calls and records the rate limit remaining from response headers.
+type MetricsTransport struct {
+	base http.RoundTripper
+}
+
+func NewMetricsTransport(base http.RoundTripper) *MetricsTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &MetricsTransport{
+		base: base,
+	}
+}
+
+func (tr *MetricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	op, ok := req.Context().Value(operationKey).(string)
+	if !ok {
+		op = "other"
+	}
+
+	resp, err := tr.base.RoundTrip(req)
+	if err != nil {
+		metrics.GithubAPICalls.Inc(op, "error")
+		return resp, err
+	}
+
+	metrics.GithubAPICalls.Inc(op, strconv.Itoa(resp.StatusCode))
+	if remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err == nil {
+		metrics.GithubRateLimitRemaining.Set(float64(remaining))
+	}
+	return resp, nil
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/google/go-github/github"
 )
@@ -38,6 +39,12 @@ type Object struct {
 
 	hasCheckEvent bool
 	checkEvent    *CheckEvent
+
+	hasTitle bool
+	title    string
+
+	hasAssignee bool
+	assignee    string
 }
 
 func NewObject(payload interface{}) *Object {
@@ -85,6 +92,14 @@ func NewObject(payload interface{}) *Object {
 	if obj.checkEvent, err = obj.GetCheckEvent(); err == nil {
 		obj.hasCheckEvent = true
 	}
+
+	if obj.title, err = obj.GetTitle(); err == nil {
+		obj.hasTitle = true
+	}
+
+	if obj.assignee, err = obj.GetAssignee(); err == nil {
+		obj.hasAssignee = true
+	}
 	return obj
 }
 
@@ -120,6 +135,50 @@ func (obj *Object) Labels() (labels []string, ok bool) {
 	return obj.labels, obj.hasLabels
 }
 
+// ApplyLabelOperation updates labels of the object after a label operation succeeded,
+// so plugins handling the same event later can see the change.
+func (obj *Object) ApplyLabelOperation(op interface{}) {
+	if !obj.hasLabels {
+		return
+	}
+
+	labels := make([]string, 0, len(obj.labels))
+	switch v := op.(type) {
+	case *AddLabelOperation:
+		labels = append(labels, obj.labels...)
+		for _, name := range v.Labels {
+			if !stringContains(labels, name) {
+				labels = append(labels, name)
+			}
+		}
+	case *RemoveLabelOperation:
+		for _, name := range obj.labels {
+			if name != v.Label {
+				labels = append(labels, name)
+			}
+		}
+	case *ReplaceLabelOperation:
+		for _, name := range obj.labels {
+			if !strings.HasPrefix(name, v.ReplaceLabelPrefix) {
+				labels = append(labels, name)
+			}
+		}
+		labels = append(labels, v.Labels...)
+	default:
+		return
+	}
+	obj.labels = labels
+}
+
+func stringContains(strs []string, s string) bool {
+	for _, v := range strs {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (obj *Object) IssueHTMLURL() (url string, ok bool) {
 	return obj.issueHTMLURL, obj.hasIssueHTMLURL
 }
@@ -132,12 +191,21 @@ func (obj *Object) CheckEvent() (event *CheckEvent, ok bool) {
 	return obj.checkEvent, obj.hasCheckEvent
 }
 
+func (obj *Object) Title() (title string, ok bool) {
+	return obj.title, obj.hasTitle
+}
+
+// Assignee is the user assigned or unassigned in issues event.
+func (obj *Object) Assignee() (assignee string, ok bool) {
+	return obj.assignee, obj.hasAssignee
+}
+
 func (obj *Object) GetAuthor() (author string, err error) {
 	switch v := obj.payload.(type) {
 	case GetIssueInterface:
 		author = v.GetIssue().GetUser().GetLogin()
 	case GetPullRequestInterface:
-		v.GetPullRequest().GetUser().GetLogin()
+		author = v.GetPullRequest().GetUser().GetLogin()
 	default:
 		err = fmt.Errorf("can't get author from payload")
 		return
@@ -177,6 +245,8 @@ func (obj *Object) GetBody() (body string, err error) {
 		body = v.GetPullRequest().GetBody()
 	case *github.PullRequestReviewCommentEvent:
 		body = v.GetComment().GetBody()
+	case *github.IssuesEvent:
+		body = v.GetIssue().GetBody()
 	default:
 		err = fmt.Errorf("can't get msg from payload")
 		return
@@ -184,6 +254,32 @@ func (obj *Object) GetBody() (body string, err error) {
 	return
 }
 
+func (obj *Object) GetTitle() (title string, err error) {
+	switch v := obj.payload.(type) {
+	case GetIssueInterface:
+		title = v.GetIssue().GetTitle()
+	case GetPullRequestInterface:
+		title = v.GetPullRequest().GetTitle()
+	default:
+		err = fmt.Errorf("can't get title from payload")
+	}
+	return
+}
+
+func (obj *Object) GetAssignee() (assignee string, err error) {
+	switch v := obj.payload.(type) {
+	case GetAssigneeInterface:
+		if v.GetAssignee() == nil {
+			err = fmt.Errorf("can't get assignee from payload")
+			return
+		}
+		assignee = v.GetAssignee().GetLogin()
+	default:
+		err = fmt.Errorf("can't get assignee from payload")
+	}
+	return
+}
+
 func (obj *Object) GetNumber() (number int, err error) {
 	switch v := obj.payload.(type) {
 	case GetIssueInterface:
@@ -323,6 +419,10 @@ type GetReviewInterface interface {
 	GetReview() *github.PullRequestReview
 }
 
+type GetAssigneeInterface interface {
+	GetAssignee() *github.User
+}
+
 type GetInstallationInterface interface {
 	GetInstallation() *github.Installation
 }
@@ -7,6 +7,7 @@ import (
 )
 
 func (cli *githubClient) CheckMergeable(ctx context.Context, owner, repo string, number int) (bool, error) {
+	ctx = WithOperation(ctx, "CheckMergeable")
 	pr, _, err := cli.client.PullRequests.Get(ctx, owner, repo, number)
 	if err != nil {
 		return false, err
@@ -20,6 +21,7 @@ func (cli *githubClient) CheckMergeable(ctx context.Context, owner, repo string,
 }
 
 func (cli *githubClient) ListPullRequestBySHA(ctx context.Context, owner, repo string, sha string) (prs []PullRequest, err error) {
+	ctx = WithOperation(ctx, "ListPullRequestBySHA")
 	prs = make([]PullRequest, 0)
 	step := 50
 	page := 1
@@ -69,6 +71,7 @@ func (cli *githubClient) ListPullRequestBySHA(ctx context.Context, owner, repo s
 }
 
 func (cli *githubClient) ListFilesByPullRequest(ctx context.Context, owner, repo string, number int) (files []string, err error) {
+	ctx = WithOperation(ctx, "ListFilesByPullRequest")
 	files = make([]string, 0)
 	step := 200
 	page := 1
@@ -105,11 +108,14 @@ type RequestReviewsOperation struct {
 	Repo      string
 	Number    int
 	Reviewers []string
+	// slugs of teams in the org owning the repo
+	TeamReviewers []string
 }
 
 func (cli *githubClient) doRequestReviewsOperation(ctx context.Context, op *RequestReviewsOperation) error {
 	_, _, err := cli.client.PullRequests.RequestReviewers(ctx, op.Owner, op.Repo, op.Number, github.ReviewersRequest{
-		Reviewers: op.Reviewers,
+		Reviewers:     op.Reviewers,
+		TeamReviewers: op.TeamReviewers,
 	})
 	return err
 }
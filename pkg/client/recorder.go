@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+// OperationRecord is an operation done by DoOperation.
+type OperationRecord struct {
+	Type  string      `json:"type"`
+	Args  interface{} `json:"args"`
+	Error string      `json:"error,omitempty"`
+	// the operation is not done because the plugin runs in dry-run mode
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// OperationRecorder records operations done with a context, see WithOperationRecorder.
+type OperationRecorder struct {
+	records []OperationRecord
+	mu      sync.Mutex
+}
+
+func NewOperationRecorder() *OperationRecorder {
+	return &OperationRecorder{
+		records: make([]OperationRecord, 0),
+	}
+}
+
+func (r *OperationRecorder) Record(op interface{}, err error) {
+	record := OperationRecord{
+		Type: OperationName(op),
+		Args: op,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	r.records = append(r.records, record)
+	r.mu.Unlock()
+}
+
+// RecordDryRun records op which would have been done if not in dry-run mode.
+func (r *OperationRecorder) RecordDryRun(op interface{}) {
+	r.mu.Lock()
+	r.records = append(r.records, OperationRecord{
+		Type:   OperationName(op),
+		Args:   op,
+		DryRun: true,
+	})
+	r.mu.Unlock()
+}
+
+func (r *OperationRecorder) Records() []OperationRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]OperationRecord{}, r.records...)
+}
+
+type recorderKeyType struct{}
+
+var recorderKey = recorderKeyType{}
+
+// WithOperationRecorder returns a context, operations done with it are recorded by r.
+func WithOperationRecorder(ctx context.Context, r *OperationRecorder) context.Context {
+	return context.WithValue(ctx, recorderKey, r)
+}
+
+func OperationRecorderFromContext(ctx context.Context) (*OperationRecorder, bool) {
+	r, ok := ctx.Value(recorderKey).(*OperationRecorder)
+	return r, ok
+}
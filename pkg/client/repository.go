@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	ErrFileNotFound = errors.New("file not found")
+)
+
+// GetFileContent returns content of the file in the default branch,
+// ErrFileNotFound is returned if the file doesn't exist.
+func (cli *githubClient) GetFileContent(ctx context.Context, owner, repo, path string) ([]byte, error) {
+	ctx = WithOperation(ctx, "GetFileContent")
+	file, _, resp, err := cli.client.Repositories.GetContents(ctx, owner, repo, path, nil)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, ErrFileNotFound
+		}
+		return nil, err
+	}
+	// path is a directory
+	if file == nil {
+		return nil, ErrFileNotFound
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
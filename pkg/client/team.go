@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// ListTeamMembers returns logins of members of the team, child teams' members are included.
+func (cli *githubClient) ListTeamMembers(ctx context.Context, org, slug string) (members []string, err error) {
+	ctx = WithOperation(ctx, "ListTeamMembers")
+	members = make([]string, 0)
+	step := 100
+	page := 1
+	for {
+		// go-github doesn't support getting teams by slug, request the api directly
+		u := fmt.Sprintf("orgs/%s/teams/%s/members?page=%d&per_page=%d", org, slug, page, step)
+		req, err := cli.client.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var users []*github.User
+		if _, err = cli.client.Do(ctx, req, &users); err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			members = append(members, user.GetLogin())
+		}
+
+		// no more members
+		if len(users) < step {
+			break
+		}
+		page++
+	}
+	return
+}
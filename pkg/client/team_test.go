@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// newFakeTeamServer serves members of team org/slug in pages, requests of each page are counted.
+func newFakeTeamServer(t *testing.T, org, slug string, members []string) (*httptest.Server, map[int]int) {
+	requests := make(map[int]int)
+	mux := http.NewServeMux()
+	mux.HandleFunc(fmt.Sprintf("/orgs/%s/teams/%s/members", org, slug), func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if page <= 0 || perPage <= 0 {
+			t.Errorf("page and per_page should be set, got %q", r.URL.RawQuery)
+			w.WriteHeader(400)
+			return
+		}
+		requests[page]++
+
+		users := make([]*github.User, 0)
+		for i := (page - 1) * perPage; i < page*perPage && i < len(members); i++ {
+			users = append(users, &github.User{Login: github.String(members[i])})
+		}
+		json.NewEncoder(w).Encode(users)
+	})
+	return httptest.NewServer(mux), requests
+}
+
+func newTestClient(t *testing.T, serverURL string) ClientInterface {
+	cli := github.NewClient(nil)
+	u, err := url.Parse(serverURL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.BaseURL = u
+	return NewGithubClient(cli)
+}
+
+func TestListTeamMembersPagination(t *testing.T) {
+	tests := []struct {
+		name      string
+		count     int
+		wantPages int
+	}{
+		{name: "empty", count: 0, wantPages: 1},
+		{name: "one page", count: 30, wantPages: 1},
+		{name: "two pages", count: 150, wantPages: 2},
+		// the last page is empty if the count is a multiple of the page size
+		{name: "full pages", count: 200, wantPages: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			members := make([]string, 0, tt.count)
+			for i := 0; i < tt.count; i++ {
+				members = append(members, fmt.Sprintf("user%d", i))
+			}
+			server, requests := newFakeTeamServer(t, "org", "team", members)
+			defer server.Close()
+
+			got, err := newTestClient(t, server.URL).ListTeamMembers(context.Background(), "org", "team")
+			if err != nil {
+				t.Fatalf("ListTeamMembers error: %v", err)
+			}
+			if len(got) != tt.count {
+				t.Fatalf("got %d members, want %d", len(got), tt.count)
+			}
+			for i, login := range got {
+				if login != members[i] {
+					t.Fatalf("members[%d] = %q, want %q", i, login, members[i])
+				}
+			}
+			if len(requests) != tt.wantPages {
+				t.Errorf("requested pages %v, want %d pages", requests, tt.wantPages)
+			}
+			for page, n := range requests {
+				if n != 1 {
+					t.Errorf("page %d requested %d times", page, n)
+				}
+			}
+		})
+	}
+}
+
+func TestListTeamMembersError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(404)
+		w.Write([]byte(`{"message": "Not Found"}`))
+	}))
+	defer server.Close()
+
+	if _, err := newTestClient(t, server.URL).ListTeamMembers(context.Background(), "org", "team"); err == nil {
+		t.Fatal("ListTeamMembers should fail if the team is not found")
+	}
+}
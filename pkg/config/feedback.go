@@ -0,0 +1,26 @@
+package config
+
+const (
+	// failures of commands are only logged
+	FeedbackOff = "off"
+	// a reaction is added to the comment containing the failed command
+	FeedbackReaction = "reaction"
+	// the reason is replied as a comment
+	FeedbackComment = "comment"
+)
+
+// FeedbackOptions decides how users are told when their commands are rejected or fail.
+type FeedbackOptions struct {
+	Mode string `json:"mode"`
+	// at most one feedback is sent to an issue or pull request in the interval, default is 60
+	IntervalS int `json:"interval_s"`
+}
+
+// IsValidFeedbackMode returns true if mode is empty, which means off, or a known mode.
+func IsValidFeedbackMode(mode string) bool {
+	switch mode {
+	case "", FeedbackOff, FeedbackReaction, FeedbackComment:
+		return true
+	}
+	return false
+}
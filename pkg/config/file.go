@@ -0,0 +1,260 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IsYAMLFile returns true if the file should be parsed as YAML by its extension.
+func IsYAMLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// LoadFile reads the file and unmarshals it into v,
+// files with .yaml or .yml extension are parsed as YAML, others are parsed as JSON.
+// References to environment variables and files in strings are replaced, see interpolate.go.
+func LoadFile(path string, v interface{}) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err = Unmarshal(path, content, v); err != nil {
+		return fmt.Errorf("parse file [%s] error: %v", path, err)
+	}
+	if err = interpolateFile(path, content, v, false); err != nil {
+		return fmt.Errorf("interpolate file [%s] error: %v", path, err)
+	}
+	return nil
+}
+
+// Unmarshal parses content in the format chosen by extension of path, see LoadFile.
+// YAML is converted to JSON first so json tags are used and results are the same as JSON files.
+// Errors contain the line and column where the problem is found.
+func Unmarshal(path string, content []byte, v interface{}) error {
+	if IsYAMLFile(path) {
+		return unmarshalYAML(content, v, false)
+	}
+	return unmarshalJSON(content, v, false)
+}
+
+// UnmarshalStrict is like Unmarshal but returns an error if there are unknown fields.
+func UnmarshalStrict(path string, content []byte, v interface{}) error {
+	if IsYAMLFile(path) {
+		return unmarshalYAML(content, v, true)
+	}
+	return unmarshalJSON(content, v, true)
+}
+
+// decodeJSON decodes content into v, unknown fields are not allowed if strict is true.
+func decodeJSON(content []byte, v interface{}, strict bool) error {
+	if !strict {
+		return json.Unmarshal(content, v)
+	}
+
+	// check syntax first as json.Unmarshal does
+	var raw json.RawMessage
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+func unmarshalJSON(content []byte, v interface{}, strict bool) error {
+	err := decodeJSON(content, v, strict)
+	if err == nil {
+		return nil
+	}
+
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return fmt.Errorf("%s", strings.TrimPrefix(err.Error(), "json: "))
+	}
+	line, column := position(content, int(offset))
+	return fmt.Errorf("line %d, column %d: %s", line, column, strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// position returns line and column of offset in content, both start from 1.
+func position(content []byte, offset int) (line int, column int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	line = 1 + bytes.Count(content[:offset], []byte("\n"))
+	column = offset - bytes.LastIndexByte(content[:offset], '\n')
+	return
+}
+
+func unmarshalYAML(content []byte, v interface{}, strict bool) error {
+	var node yaml.Node
+	if err := yaml.Unmarshal(content, &node); err != nil {
+		// syntax errors from yaml only contain the line
+		return fmt.Errorf("%s", strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	c := &yamlConverter{}
+	if err := c.convert(&node); err != nil {
+		return err
+	}
+
+	err := decodeJSON(c.buf.Bytes(), v, strict)
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*json.UnmarshalTypeError); ok {
+		line, column := c.position(int(e.Offset))
+		return fmt.Errorf("line %d, column %d: %s", line, column, strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return fmt.Errorf("%s", strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// yamlMark records where a converted value is in JSON and in the original YAML.
+type yamlMark struct {
+	start  int
+	end    int
+	line   int
+	column int
+}
+
+// yamlConverter converts YAML nodes to JSON and remembers their positions.
+type yamlConverter struct {
+	buf   bytes.Buffer
+	marks []*yamlMark
+}
+
+// position returns the YAML position of the value where json decoder stopped at offset.
+func (c *yamlConverter) position(offset int) (line int, column int) {
+	var found *yamlMark
+	for _, m := range c.marks {
+		// type errors of scalars are reported after the value,
+		// errors of objects and arrays are reported after the first byte
+		if m.end == offset || m.start == offset-1 {
+			found = m
+		} else if found == nil && m.start < offset {
+			line, column = m.line, m.column
+		}
+	}
+	if found != nil {
+		return found.line, found.column
+	}
+	return
+}
+
+func (c *yamlConverter) convert(n *yaml.Node) (err error) {
+	m := &yamlMark{
+		start:  c.buf.Len(),
+		line:   n.Line,
+		column: n.Column,
+	}
+	c.marks = append(c.marks, m)
+	defer func() {
+		m.end = c.buf.Len()
+	}()
+
+	switch n.Kind {
+	case 0:
+		// empty file
+		c.buf.WriteString("null")
+		return nil
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			c.buf.WriteString("null")
+			return nil
+		}
+		return c.convert(n.Content[0])
+	case yaml.AliasNode:
+		return c.convert(n.Alias)
+	case yaml.SequenceNode:
+		c.buf.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				c.buf.WriteByte(',')
+			}
+			if err = c.convert(item); err != nil {
+				return err
+			}
+		}
+		c.buf.WriteByte(']')
+		return nil
+	case yaml.MappingNode:
+		pairs, err := mappingPairs(n)
+		if err != nil {
+			return err
+		}
+		c.buf.WriteByte('{')
+		for i := 0; i < len(pairs); i += 2 {
+			if i > 0 {
+				c.buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(pairs[i].Value)
+			c.buf.Write(key)
+			c.buf.WriteByte(':')
+			if err = c.convert(pairs[i+1]); err != nil {
+				return err
+			}
+		}
+		c.buf.WriteByte('}')
+		return nil
+	case yaml.ScalarNode:
+		var value interface{}
+		if err = n.Decode(&value); err != nil {
+			return fmt.Errorf("line %d, column %d: %v", n.Line, n.Column, err)
+		}
+		buf, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("line %d, column %d: %v", n.Line, n.Column, err)
+		}
+		c.buf.Write(buf)
+		return nil
+	default:
+		return fmt.Errorf("line %d, column %d: unknown yaml node", n.Line, n.Column)
+	}
+}
+
+// mappingPairs returns key and value nodes of the mapping, keys merged by "<<" come first
+// so they can be overridden by keys defined explicitly.
+func mappingPairs(n *yaml.Node) ([]*yaml.Node, error) {
+	merged := make([]*yaml.Node, 0)
+	pairs := make([]*yaml.Node, 0, len(n.Content))
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d, column %d: mapping key must be a scalar", key.Line, key.Column)
+		}
+
+		if key.Tag == "!!merge" {
+			sources := []*yaml.Node{value}
+			if value.Kind == yaml.SequenceNode {
+				sources = value.Content
+			}
+			for _, src := range sources {
+				if src.Kind == yaml.AliasNode {
+					src = src.Alias
+				}
+				if src.Kind != yaml.MappingNode {
+					return nil, fmt.Errorf("line %d, column %d: map merge requires map or sequence of maps", src.Line, src.Column)
+				}
+				srcPairs, err := mappingPairs(src)
+				if err != nil {
+					return nil, err
+				}
+				merged = append(merged, srcPairs...)
+			}
+			continue
+		}
+		pairs = append(pairs, key, value)
+	}
+	return append(merged, pairs...), nil
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// References in config strings are replaced when config files are loaded:
+//
+//	${ENV:NAME}   value of environment variable NAME, it must be set
+//	${FILE:/path} content of the file with trailing newlines trimmed
+//	$${...}       the literal string ${...}
+const (
+	refPrefix = "${"
+	refSuffix = "}"
+
+	RefKindEnv  = "ENV"
+	RefKindFile = "FILE"
+)
+
+// InterpolateError is the error of a reference, Path is the location of the string in config.
+type InterpolateError struct {
+	Path string
+	Err  error
+}
+
+func (e *InterpolateError) Error() string {
+	if e.Path == "" {
+		return e.Err.Error()
+	}
+	return e.Path + ": " + e.Err.Error()
+}
+
+// interpolateFile replaces references in strings of v decoded from content.
+// v is decoded again from the interpolated config so it is only done if content has references.
+func interpolateFile(path string, content []byte, v interface{}, strict bool) error {
+	if !strings.Contains(string(content), refPrefix) {
+		return nil
+	}
+
+	var generic interface{}
+	if err := Unmarshal(path, content, &generic); err != nil {
+		return err
+	}
+	generic, err := interpolateValue("", generic, resolveRef)
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(generic)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+	return decodeJSON(buf, v, strict)
+}
+
+// CheckReferences returns errors of malformed references in content without resolving them.
+func CheckReferences(path string, content []byte) []*InterpolateError {
+	errs := make([]*InterpolateError, 0)
+	if !strings.Contains(string(content), refPrefix) {
+		return errs
+	}
+
+	var generic interface{}
+	if err := Unmarshal(path, content, &generic); err != nil {
+		return errs
+	}
+	walkStrings("", generic, func(valuePath string, s string) {
+		if _, err := interpolateString(s, func(kind, name string) (string, error) {
+			return "", nil
+		}); err != nil {
+			errs = append(errs, &InterpolateError{Path: valuePath, Err: err})
+		}
+	})
+	return errs
+}
+
+func interpolateValue(valuePath string, v interface{}, resolve func(kind, name string) (string, error)) (interface{}, error) {
+	switch value := v.(type) {
+	case string:
+		out, err := interpolateString(value, resolve)
+		if err != nil {
+			return nil, &InterpolateError{Path: valuePath, Err: err}
+		}
+		return out, nil
+	case map[string]interface{}:
+		for _, k := range sortedKeys(value) {
+			out, err := interpolateValue(joinPath(valuePath, k), value[k], resolve)
+			if err != nil {
+				return nil, err
+			}
+			value[k] = out
+		}
+	case []interface{}:
+		for i := range value {
+			out, err := interpolateValue(fmt.Sprintf("%s[%d]", valuePath, i), value[i], resolve)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = out
+		}
+	}
+	return v, nil
+}
+
+func walkStrings(valuePath string, v interface{}, fn func(valuePath string, s string)) {
+	switch value := v.(type) {
+	case string:
+		fn(valuePath, value)
+	case map[string]interface{}:
+		for _, k := range sortedKeys(value) {
+			walkStrings(joinPath(valuePath, k), value[k], fn)
+		}
+	case []interface{}:
+		for i := range value {
+			walkStrings(fmt.Sprintf("%s[%d]", valuePath, i), value[i], fn)
+		}
+	}
+}
+
+// interpolateString replaces all references in s by values returned from resolve.
+func interpolateString(s string, resolve func(kind, name string) (string, error)) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, refPrefix)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+
+		// $${ is escaped
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString(refPrefix)
+			s = s[i+len(refPrefix):]
+			continue
+		}
+
+		b.WriteString(s[:i])
+		s = s[i+len(refPrefix):]
+		j := strings.Index(s, refSuffix)
+		if j < 0 {
+			return "", fmt.Errorf("reference is not closed")
+		}
+		ref := s[:j]
+		s = s[j+len(refSuffix):]
+
+		arrs := strings.SplitN(ref, ":", 2)
+		if len(arrs) != 2 || arrs[1] == "" {
+			return "", fmt.Errorf("reference ${%s} should be ${ENV:NAME} or ${FILE:/path}", ref)
+		}
+		if arrs[0] != RefKindEnv && arrs[0] != RefKindFile {
+			return "", fmt.Errorf("unknown reference kind [%s] in ${%s}", arrs[0], ref)
+		}
+		value, err := resolve(arrs[0], arrs[1])
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+	}
+}
+
+// resolveRef returns the value of the reference, values are registered as secrets to be redacted.
+func resolveRef(kind, name string) (string, error) {
+	var value string
+	switch kind {
+	case RefKindEnv:
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable [%s] is not set", name)
+		}
+		value = v
+	case RefKindFile:
+		buf, err := ioutil.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		value = strings.TrimRight(string(buf), "\r\n")
+	}
+	AddSecret(value)
+	return value, nil
+}
+
+func joinPath(valuePath string, key string) string {
+	if strings.ContainsAny(key, "/.*[] ") {
+		return fmt.Sprintf("%s[%q]", valuePath, key)
+	}
+	if valuePath == "" {
+		return key
+	}
+	return valuePath + "." + key
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
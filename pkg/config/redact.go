@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+const (
+	RedactedValue = "******"
+
+	// shorter values are not redacted to avoid hiding unrelated text
+	minSecretLength = 6
+)
+
+var (
+	// values resolved from references in config
+	secrets   = make(map[string]struct{})
+	secretsMu sync.RWMutex
+
+	// values of fields whose names contain these words are redacted
+	secretFieldWords = []string{"secret", "token", "password", "private_key", "url"}
+)
+
+// AddSecret registers a value to be redacted by Redact and RedactValue.
+func AddSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secrets[value] = struct{}{}
+}
+
+// Redact replaces secrets registered in s.
+func Redact(s string) string {
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for secret := range secrets {
+		s = strings.Replace(s, secret, RedactedValue, -1)
+	}
+	return s
+}
+
+// RedactValue returns a json compatible copy of v, values of secret fields and registered secrets are redacted.
+func RedactValue(v interface{}) interface{} {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var generic interface{}
+	if err = json.Unmarshal(buf, &generic); err != nil {
+		return nil
+	}
+	return redactValue(generic, false)
+}
+
+func redactValue(v interface{}, secretField bool) interface{} {
+	switch value := v.(type) {
+	case string:
+		if secretField && value != "" {
+			return RedactedValue
+		}
+		return Redact(value)
+	case map[string]interface{}:
+		for k, item := range value {
+			value[k] = redactValue(item, secretField || isSecretField(k))
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = redactValue(item, secretField)
+		}
+	}
+	return v
+}
+
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range secretFieldWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,5 +1,33 @@
 package config
 
-type RoleOptions map[string][]string // role -> []string{user1, user2}
+import "strings"
+
+// entries of roles prefixed with TeamRolePrefix refer to github teams, e.g. team:fatedier/reviewers
+const TeamRolePrefix = "team:"
+
+type RoleOptions map[string][]string // role -> []string{user1, user2, team:org/slug}
 
 type LabelRoles map[string]map[string][]string // label -> role -> users
+
+// Roles returns all roles used in label roles.
+func (lr LabelRoles) Roles() map[string]struct{} {
+	out := make(map[string]struct{})
+	for _, roles := range lr {
+		for role := range roles {
+			out[role] = struct{}{}
+		}
+	}
+	return out
+}
+
+// ParseTeamRole returns org and slug of the team if entry is a team reference.
+func ParseTeamRole(entry string) (org string, slug string, ok bool) {
+	if !strings.HasPrefix(entry, TeamRolePrefix) {
+		return "", "", false
+	}
+	arrs := strings.Split(strings.TrimPrefix(entry, TeamRolePrefix), "/")
+	if len(arrs) != 2 || arrs[0] == "" || arrs[1] == "" {
+		return "", "", false
+	}
+	return arrs[0], arrs[1], true
+}
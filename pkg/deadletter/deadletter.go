@@ -0,0 +1,136 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileSuffix = ".json"
+)
+
+// ErrNotFound is returned by Get if no entry has the id.
+var ErrNotFound = errors.New("failed event not found")
+
+// Entry is a delivery failed to be handled by some plugins.
+type Entry struct {
+	ID         string `json:"id"`
+	DeliveryID string `json:"delivery_id"`
+	EventType  string `json:"event_type"`
+	Repo       string `json:"repo"`
+	Payload    string `json:"payload,omitempty"`
+	// plugin -> error, only plugins failed are recorded
+	Errors      map[string]string `json:"errors"`
+	ReplayCount int               `json:"replay_count"`
+	FailedAt    time.Time         `json:"failed_at"`
+}
+
+// FailedPlugins returns names of failed plugins in order.
+func (e *Entry) FailedPlugins() []string {
+	out := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Store saves every entry as a file in dir.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Store{
+		dir: dir,
+	}, nil
+}
+
+// Save creates or updates the entry, ID is generated if empty.
+func (s *Store) Save(e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e.ID == "" {
+		e.ID = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	if e.FailedAt.IsZero() {
+		e.FailedAt = time.Now()
+	}
+
+	buf, err := json.MarshalIndent(e, "", "    ")
+	if err != nil {
+		return err
+	}
+	path := s.filePath(e.ID)
+	if err = ioutil.WriteFile(path+".tmp", buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (s *Store) Get(id string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buf, err := ioutil.ReadFile(s.filePath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	e := &Entry{}
+	if err = json.Unmarshal(buf, e); err != nil {
+		return nil, fmt.Errorf("parse failed event [%s] error: %v", id, err)
+	}
+	return e, nil
+}
+
+// List returns all entries ordered by failed time, payloads are not included.
+func (s *Store) List() ([]*Entry, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*Entry, 0)
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileSuffix) {
+			continue
+		}
+
+		e, err := s.Get(strings.TrimSuffix(file.Name(), fileSuffix))
+		if err != nil {
+			return nil, err
+		}
+		e.Payload = ""
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].FailedAt.Before(out[j].FailedAt)
+	})
+	return out, nil
+}
+
+func (s *Store) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.Remove(s.filePath(id))
+}
+
+func (s *Store) filePath(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+fileSuffix)
+}
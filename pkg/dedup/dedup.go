@@ -0,0 +1,228 @@
+package dedup
+
+import (
+	"bufio"
+	"container/list"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store records ids of deliveries seen recently.
+type Store interface {
+	// CheckAndAdd records id and returns true if it has been seen and not expired.
+	CheckAndAdd(id string) (seen bool)
+	// Remove forgets id, the next delivery with the same id will be processed.
+	Remove(id string)
+}
+
+type entry struct {
+	id       string
+	expireAt time.Time
+}
+
+var _ Store = &MemoryStore{}
+
+// MemoryStore keeps at most maxEntries ids, each id expires after ttl.
+type MemoryStore struct {
+	ttl        time.Duration
+	maxEntries int
+
+	// entries ordered by insertion time
+	entries *list.List
+	index   map[string]*list.Element
+	mu      sync.Mutex
+}
+
+func NewMemoryStore(ttl time.Duration, maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    list.New(),
+		index:      make(map[string]*list.Element),
+	}
+}
+
+func (s *MemoryStore) CheckAndAdd(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.evictLocked(now)
+	if _, ok := s.index[id]; ok {
+		return true
+	}
+	s.addLocked(id, now.Add(s.ttl))
+	return false
+}
+
+func (s *MemoryStore) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.index[id]; ok {
+		s.entries.Remove(elem)
+		delete(s.index, id)
+	}
+}
+
+func (s *MemoryStore) addLocked(id string, expireAt time.Time) {
+	s.index[id] = s.entries.PushBack(&entry{
+		id:       id,
+		expireAt: expireAt,
+	})
+	for s.maxEntries > 0 && s.entries.Len() > s.maxEntries {
+		s.removeElemLocked(s.entries.Front())
+	}
+}
+
+func (s *MemoryStore) evictLocked(now time.Time) {
+	for elem := s.entries.Front(); elem != nil; elem = s.entries.Front() {
+		if elem.Value.(*entry).expireAt.After(now) {
+			break
+		}
+		s.removeElemLocked(elem)
+	}
+}
+
+func (s *MemoryStore) removeElemLocked(elem *list.Element) {
+	s.entries.Remove(elem)
+	delete(s.index, elem.Value.(*entry).id)
+}
+
+// snapshot returns all entries not expired.
+func (s *MemoryStore) snapshot() []entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictLocked(time.Now())
+	out := make([]entry, 0, s.entries.Len())
+	for elem := s.entries.Front(); elem != nil; elem = elem.Next() {
+		out = append(out, *elem.Value.(*entry))
+	}
+	return out
+}
+
+var _ Store = &FileStore{}
+
+// FileStore is a MemoryStore which appends every change to a file,
+// ids are loaded from the file when created so they survive restarts.
+//
+// Each line of the file is "+ {id} {expire unix}" or "- {id}".
+type FileStore struct {
+	*MemoryStore
+
+	path  string
+	file  *os.File
+	lines int
+	mu    sync.Mutex
+}
+
+func NewFileStore(path string, ttl time.Duration, maxEntries int) (*FileStore, error) {
+	s := &FileStore{
+		MemoryStore: NewMemoryStore(ttl, maxEntries),
+		path:        path,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("load dedup file [%s] error: %v", path, err)
+	}
+	if err := s.compact(); err != nil {
+		return nil, fmt.Errorf("compact dedup file [%s] error: %v", path, err)
+	}
+	return s, nil
+}
+
+func (s *FileStore) CheckAndAdd(id string) bool {
+	if s.MemoryStore.CheckAndAdd(id) {
+		return true
+	}
+	s.appendLine(fmt.Sprintf("+ %s %d", id, time.Now().Add(s.ttl).Unix()))
+	return false
+}
+
+func (s *FileStore) Remove(id string) {
+	s.MemoryStore.Remove(id)
+	s.appendLine(fmt.Sprintf("- %s", id))
+}
+
+func (s *FileStore) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		arrs := strings.Fields(scanner.Text())
+		switch {
+		case len(arrs) == 3 && arrs[0] == "+":
+			expire, err := strconv.ParseInt(arrs[2], 10, 64)
+			if err != nil {
+				continue
+			}
+			expireAt := time.Unix(expire, 0)
+			if expireAt.After(now) {
+				s.MemoryStore.Remove(arrs[1])
+				s.MemoryStore.mu.Lock()
+				s.MemoryStore.addLocked(arrs[1], expireAt)
+				s.MemoryStore.mu.Unlock()
+			}
+		case len(arrs) == 2 && arrs[0] == "-":
+			s.MemoryStore.Remove(arrs[1])
+		}
+	}
+	return scanner.Err()
+}
+
+// compact rewrites the file with entries in memory only.
+func (s *FileStore) compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := s.MemoryStore.snapshot()
+	tmpPath := s.path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, e := range entries {
+		fmt.Fprintf(w, "+ %s %d\n", e.id, e.expireAt.Unix())
+	}
+	if err = w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	if err = os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+
+	if s.file != nil {
+		s.file.Close()
+	}
+	s.file, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0644)
+	s.lines = len(entries)
+	return err
+}
+
+func (s *FileStore) appendLine(line string) {
+	s.mu.Lock()
+	if s.file != nil {
+		s.file.WriteString(line + "\n")
+	}
+	s.lines++
+	needCompact := s.maxEntries > 0 && s.lines > 2*s.maxEntries
+	s.mu.Unlock()
+
+	if needCompact {
+		s.compact()
+	}
+}
@@ -0,0 +1,16 @@
+package errutil
+
+import (
+	"fmt"
+)
+
+// Append joins partialErr to err, nil errors are ignored.
+func Append(err error, partialErr error) error {
+	if partialErr == nil {
+		return err
+	}
+	if err == nil {
+		return partialErr
+	}
+	return fmt.Errorf("%v; %v", err, partialErr)
+}
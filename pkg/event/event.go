@@ -7,6 +7,7 @@ import (
 )
 
 const (
+	EvIssues                   = "issues"
 	EvIssueComment             = "issue_comment"
 	EvPullRequest              = "pull_request"
 	EvPullRequestReview        = "pull_request_review"
@@ -14,6 +15,9 @@ const (
 	EvCheckRun                 = "check_run"
 	EvCheckSuite               = "check_suite"
 	EvPing                     = "ping"
+	EvPush                     = "push"
+	EvMembership               = "membership"
+	EvTeam                     = "team"
 )
 
 const (
@@ -29,6 +33,12 @@ const (
 	ActionCompleted            = "completed"
 	ActionReviewRequested      = "review_requested"
 	ActionReviewRequestRemoved = "review_request_removed"
+	ActionEdited               = "edited"
+	ActionAssigned             = "assigned"
+	ActionUnassigned           = "unassigned"
+	ActionTransferred          = "transferred"
+	ActionMilestoned           = "milestoned"
+	ActionDemilestoned         = "demilestoned"
 )
 
 const (
@@ -48,6 +58,8 @@ const (
 	ObjectNeedIssueHTMLURL
 	ObjectNeedReviewState
 	ObjectNeedCheckEvent
+	ObjectNeedTitle
+	ObjectNeedAssignee
 )
 
 type EventContext struct {
@@ -6,8 +6,8 @@ import (
 )
 
 type HttpError struct {
-	code    int    `json:"-"`
-	errInfo string `json:"error"`
+	code    int
+	errInfo string
 }
 
 func (err *HttpError) Code() int {
@@ -18,16 +18,36 @@ func (err *HttpError) Error() string {
 	return err.errInfo
 }
 
+func (err *HttpError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"error": err.errInfo,
+	})
+}
+
 func ReplyError(w http.ResponseWriter, err error) {
 	switch e := err.(type) {
 	case *HttpError:
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(e.Code())
 		content, _ := json.Marshal(e)
 		w.Write(content)
 	default:
-		w.WriteHeader(599)
-		w.Write([]byte(err.Error()))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(500)
+		content, _ := json.Marshal(NewHttpError(500, err.Error()))
+		w.Write(content)
+	}
+}
+
+func ReplyJSON(w http.ResponseWriter, code int, v interface{}) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		ReplyError(w, NewHttpError(500, err.Error()))
+		return
 	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(content)
 }
 
 func NewHttpError(code int, errInfo string) *HttpError {
@@ -0,0 +1,31 @@
+package metrics
+
+// Default is the registry served on /metrics of admin api.
+var Default = NewRegistry()
+
+const (
+	OutcomeOK                 = "ok"
+	OutcomeNotSupported       = "not_supported"
+	OutcomePreconditionFailed = "precondition_failed"
+	OutcomeError              = "error"
+)
+
+var (
+	EventsReceived = Default.NewCounterVec("freebot_events_received_total",
+		"Number of webhook events received.", "event", "action", "repo")
+
+	PluginHandleDuration = Default.NewHistogramVec("freebot_plugin_handle_duration_seconds",
+		"Latency of plugins handling events.", nil, "repo", "plugin", "outcome")
+
+	GithubAPICalls = Default.NewCounterVec("freebot_github_api_calls_total",
+		"Number of github api calls by operation and status code.", "operation", "code")
+
+	RepoConfErrors = Default.NewGaugeVec("freebot_repo_conf_errors",
+		"Whether the repo conf file, repo conf or plugins of the key failed to load, 1 means failed.", "key")
+
+	RepoConfBuildFailures = Default.NewCounterVec("freebot_repo_conf_build_failures_total",
+		"Number of failures loading repo conf files, repo confs or plugins.", "key")
+
+	GithubRateLimitRemaining = Default.NewGaugeVec("freebot_github_rate_limit_remaining",
+		"Remaining github api requests in current rate limit window.")
+)
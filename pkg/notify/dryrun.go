@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/fatedier/freebot/pkg/client"
+)
+
+// NotifyOperation is recorded by DryRunNotifier, options are not recorded because they contain secrets.
+type NotifyOperation struct {
+	Content string
+}
+
+// DryRunNotifier records notifications with the operation recorder in ctx instead of sending them.
+type DryRunNotifier struct {
+}
+
+func NewDryRunNotifier() *DryRunNotifier {
+	return &DryRunNotifier{}
+}
+
+func (n *DryRunNotifier) Send(ctx context.Context, options *NotifyOptions, content string) error {
+	if options == nil {
+		return nil
+	}
+	if r, ok := client.OperationRecorderFromContext(ctx); ok {
+		r.RecordDryRun(&NotifyOperation{Content: content})
+	}
+	return nil
+}
@@ -2,9 +2,9 @@ package notify
 
 import (
 	"context"
-	"fmt"
 	"reflect"
 
+	"github.com/fatedier/freebot/pkg/errutil"
 	"github.com/fatedier/freebot/pkg/notify/slack"
 )
 
@@ -32,7 +32,7 @@ func (ctl *NotifyController) Send(ctx context.Context, options *NotifyOptions, c
 		sender := slack.NewSlackNotify(options.Slack)
 		partialErr := sender.Send(ctx, content)
 		if partialErr != nil {
-			err = fmt.Errorf("%v; %v", err, partialErr)
+			err = errutil.Append(err, partialErr)
 		}
 	}
 
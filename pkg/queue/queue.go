@@ -0,0 +1,269 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatedier/freebot/pkg/log"
+)
+
+const (
+	fileSuffix = ".json"
+)
+
+type Delivery struct {
+	ID        string `json:"id"`
+	EventType string `json:"event_type"`
+	Payload   string `json:"payload"`
+	// only these plugins are run if not empty, set by handler to skip plugins already succeeded
+	Plugins     []string  `json:"plugins"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	NextAttempt time.Time `json:"next_attempt"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type HandleFunc func(ctx context.Context, d *Delivery) error
+
+// DropFunc is called when a delivery has failed MaxAttempts times and is removed from queue.
+type DropFunc func(d *Delivery, err error)
+
+type Options struct {
+	Dir           string
+	Workers       int
+	MaxAttempts   int
+	BackoffBase   time.Duration
+	BackoffMax    time.Duration
+	HandleTimeout time.Duration
+	OnDrop        DropFunc
+}
+
+func (options *Options) Complete() {
+	if options.Workers <= 0 {
+		options.Workers = 4
+	}
+	if options.MaxAttempts <= 0 {
+		options.MaxAttempts = 5
+	}
+	if options.BackoffBase <= 0 {
+		options.BackoffBase = 5 * time.Second
+	}
+	if options.BackoffMax <= 0 {
+		options.BackoffMax = 10 * time.Minute
+	}
+	if options.HandleTimeout <= 0 {
+		options.HandleTimeout = 5 * time.Minute
+	}
+}
+
+// DiskQueue persists every delivery as a file in Dir until it is handled successfully
+// or dropped, so pending deliveries survive restarts.
+type DiskQueue struct {
+	options Options
+
+	// deliveries waiting to be handled, in flight ones are not here
+	pending map[string]*Delivery
+	// closed and replaced when a new delivery is ready
+	notifyCh chan struct{}
+	mu       sync.Mutex
+
+	wg sync.WaitGroup
+}
+
+func NewDiskQueue(options Options) (*DiskQueue, error) {
+	options.Complete()
+	if err := os.MkdirAll(options.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	q := &DiskQueue{
+		options:  options,
+		pending:  make(map[string]*Delivery),
+		notifyCh: make(chan struct{}),
+	}
+
+	files, err := ioutil.ReadDir(options.Dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileSuffix) {
+			continue
+		}
+
+		buf, err := ioutil.ReadFile(filepath.Join(options.Dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		d := &Delivery{}
+		if err = json.Unmarshal(buf, d); err != nil {
+			return nil, fmt.Errorf("parse queue file [%s] error: %v", file.Name(), err)
+		}
+		q.pending[d.ID] = d
+	}
+	return q, nil
+}
+
+// Len returns the number of deliveries not handled successfully yet.
+func (q *DiskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+// Push persists the delivery and makes it available to workers.
+func (q *DiskQueue) Push(d *Delivery) error {
+	if d.ID == "" {
+		d.ID = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	if d.CreatedAt.IsZero() {
+		d.CreatedAt = time.Now()
+	}
+	d.NextAttempt = d.CreatedAt
+
+	if err := q.save(d); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	q.pending[d.ID] = d
+	q.notifyLocked()
+	q.mu.Unlock()
+	return nil
+}
+
+// Run starts workers and blocks until ctx is done and all in flight deliveries are finished.
+func (q *DiskQueue) Run(ctx context.Context, fn HandleFunc) {
+	for i := 0; i < q.options.Workers; i++ {
+		q.wg.Add(1)
+		go q.worker(ctx, fn)
+	}
+	q.wg.Wait()
+}
+
+func (q *DiskQueue) worker(ctx context.Context, fn HandleFunc) {
+	defer q.wg.Done()
+	for {
+		d, ok := q.next(ctx)
+		if !ok {
+			return
+		}
+
+		// not derived from ctx, in flight deliveries should be finished during stopping
+		handleCtx, cancel := context.WithTimeout(context.Background(), q.options.HandleTimeout)
+		err := fn(handleCtx, d)
+		cancel()
+
+		if err == nil {
+			q.remove(d)
+			continue
+		}
+
+		d.Attempts++
+		d.LastError = err.Error()
+		if d.Attempts >= q.options.MaxAttempts {
+			q.remove(d)
+			if q.options.OnDrop != nil {
+				q.options.OnDrop(d, err)
+			}
+			continue
+		}
+
+		d.NextAttempt = time.Now().Add(q.backoff(d.Attempts))
+		// the delivery is still retried in memory, the old file is handled again after restarting
+		if err := q.save(d); err != nil {
+			log.Error("save delivery [%s] of event [%s] error: %v", d.ID, d.EventType, err)
+		}
+		q.mu.Lock()
+		q.pending[d.ID] = d
+		q.notifyLocked()
+		q.mu.Unlock()
+	}
+}
+
+// next blocks until one delivery is ready to be handled or ctx is done.
+func (q *DiskQueue) next(ctx context.Context) (*Delivery, bool) {
+	for {
+		q.mu.Lock()
+		var first *Delivery
+		for _, d := range q.pending {
+			if first == nil || d.NextAttempt.Before(first.NextAttempt) {
+				first = d
+			}
+		}
+
+		wait := time.Hour
+		if first != nil {
+			wait = time.Until(first.NextAttempt)
+			if wait <= 0 {
+				delete(q.pending, first.ID)
+				q.mu.Unlock()
+				return first, true
+			}
+		}
+		notifyCh := q.notifyCh
+		q.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, false
+		case <-notifyCh:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (q *DiskQueue) notifyLocked() {
+	close(q.notifyCh)
+	q.notifyCh = make(chan struct{})
+}
+
+func (q *DiskQueue) backoff(attempts int) time.Duration {
+	backoff := q.options.BackoffBase
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= q.options.BackoffMax {
+			return q.options.BackoffMax
+		}
+	}
+	return backoff
+}
+
+func (q *DiskQueue) filePath(id string) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, id)
+	return filepath.Join(q.options.Dir, name+fileSuffix)
+}
+
+// save writes to a temporary file first, avoid leaving a broken file if crashed.
+func (q *DiskQueue) save(d *Delivery) error {
+	buf, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	path := q.filePath(d.ID)
+	tmpPath := path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, buf, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func (q *DiskQueue) remove(d *Delivery) {
+	os.Remove(q.filePath(d.ID))
+}
@@ -0,0 +1,87 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// max size of responses read from remote plugins
+const maxResponseSize = 1 << 20
+
+// Client sends events to a remote plugin.
+type Client struct {
+	URL    string
+	Secret string
+	// timeout of each request, no timeout if it is 0
+	Timeout time.Duration
+
+	HTTPClient *http.Client
+}
+
+// Call sends the event and returns operations replied.
+func (c *Client) Call(ctx context.Context, ev *Event) ([]Operation, error) {
+	body, err := json.Marshal(&Request{
+		Version: ProtocolVersion,
+		Event:   *ev,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequest("POST", c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderVersion, ProtocolVersion)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if c.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign([]byte(c.Secret), timestamp, body))
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxResponseSize {
+		return nil, fmt.Errorf("response is larger than %d bytes", maxResponseSize)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("response status %d: %s", resp.StatusCode, strings.TrimSpace(string(content)))
+	}
+
+	out := &Response{}
+	if err = json.Unmarshal(content, out); err != nil {
+		return nil, fmt.Errorf("decode response error: %v", err)
+	}
+	if out.Version != ProtocolVersion {
+		return nil, fmt.Errorf("response protocol version [%s] is not supported, expect [%s]", out.Version, ProtocolVersion)
+	}
+	return out.Operations, nil
+}
@@ -0,0 +1,133 @@
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// max size of requests read from freebot
+const maxRequestSize = 10 << 20
+
+// DefaultMaxSkew is the max age of requests accepted by Handler.
+var DefaultMaxSkew = 5 * time.Minute
+
+// HandlerFunc handles an event and returns operations freebot should run.
+type HandlerFunc func(ctx context.Context, ev *Event) ([]Operation, error)
+
+// Handler serves requests from freebot, it is used by remote plugins:
+//
+//	http.ListenAndServe(":8080", remote.NewHandler(secret, func(ctx context.Context, ev *remote.Event) ([]remote.Operation, error) {
+//		return []remote.Operation{remote.Comment("hello")}, nil
+//	}))
+type Handler struct {
+	secret  string
+	handler HandlerFunc
+
+	// requests older than MaxSkew are rejected, 0 means no limit
+	MaxSkew time.Duration
+}
+
+// NewHandler returns a Handler calling fn for each event, signatures are not checked if secret is empty.
+func NewHandler(secret string, fn HandlerFunc) *Handler {
+	return &Handler{
+		secret:  secret,
+		handler: fn,
+		MaxSkew: DefaultMaxSkew,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if v := r.Header.Get(HeaderVersion); v != ProtocolVersion {
+		http.Error(w, "protocol version ["+v+"] is not supported, expect ["+ProtocolVersion+"]", http.StatusBadRequest)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, "read request error", http.StatusBadRequest)
+		return
+	}
+	if h.secret != "" {
+		err = VerifySignature([]byte(h.secret), r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature), h.MaxSkew)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	req := &Request{}
+	if err = json.Unmarshal(body, req); err != nil {
+		http.Error(w, "decode request error: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ops, err := h.handler(r.Context(), &req.Event)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ops == nil {
+		ops = make([]Operation, 0)
+	}
+
+	buf, _ := json.Marshal(&Response{
+		Version:    ProtocolVersion,
+		Operations: ops,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf)
+}
+
+// helpers building operations
+
+func AddLabels(labels ...string) Operation {
+	return Operation{Type: OpAddLabel, Labels: labels}
+}
+
+func RemoveLabels(labels ...string) Operation {
+	return Operation{Type: OpRemoveLabel, Labels: labels}
+}
+
+func ReplaceLabels(prefix string, labels ...string) Operation {
+	return Operation{Type: OpReplaceLabel, Prefix: prefix, Labels: labels}
+}
+
+func Assign(users ...string) Operation {
+	return Operation{Type: OpAssign, Users: users}
+}
+
+func Unassign(users ...string) Operation {
+	return Operation{Type: OpUnassign, Users: users}
+}
+
+func RequestReviews(users ...string) Operation {
+	return Operation{Type: OpRequestReviews, Users: users}
+}
+
+func CancelReviews(users ...string) Operation {
+	return Operation{Type: OpCancelReviews, Users: users}
+}
+
+func Comment(content string) Operation {
+	return Operation{Type: OpComment, Content: content}
+}
+
+func Close() Operation {
+	return Operation{Type: OpClose}
+}
+
+func Reopen() Operation {
+	return Operation{Type: OpReopen}
+}
+
+func Merge() Operation {
+	return Operation{Type: OpMerge}
+}
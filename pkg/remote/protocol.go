@@ -0,0 +1,82 @@
+// Package remote defines the protocol between freebot and remote plugins, it is also the SDK for writing them.
+//
+// freebot POSTs a Request with the normalized event to the endpoint of the remote plugin, the endpoint
+// replies a Response with operations, freebot checks them with preconditions configured and runs them.
+// Requests are signed with the shared secret, see Sign.
+package remote
+
+const (
+	// ProtocolVersion is changed when incompatible changes are made to the protocol.
+	ProtocolVersion = "1"
+
+	HeaderVersion   = "X-Freebot-Protocol-Version"
+	HeaderTimestamp = "X-Freebot-Timestamp"
+	HeaderSignature = "X-Freebot-Signature-256"
+)
+
+// operation types
+const (
+	OpAddLabel       = "add_label"
+	OpRemoveLabel    = "remove_label"
+	OpReplaceLabel   = "replace_label"
+	OpAssign         = "assign"
+	OpUnassign       = "unassign"
+	OpRequestReviews = "request_reviews"
+	OpCancelReviews  = "cancel_reviews"
+	OpComment        = "comment"
+	OpClose          = "close"
+	OpReopen         = "reopen"
+	OpMerge          = "merge"
+)
+
+// OperationTypes are all supported operation types.
+var OperationTypes = []string{
+	OpAddLabel, OpRemoveLabel, OpReplaceLabel, OpAssign, OpUnassign, OpRequestReviews,
+	OpCancelReviews, OpComment, OpClose, OpReopen, OpMerge,
+}
+
+type Command struct {
+	Name string   `json:"name"`
+	Args []string `json:"args"`
+}
+
+// Event is the normalized github event sent to remote plugins.
+type Event struct {
+	// github event type, e.g. issue_comment
+	Type   string `json:"type"`
+	Action string `json:"action,omitempty"`
+	Owner  string `json:"owner"`
+	Repo   string `json:"repo"`
+	// number of the issue or pull request
+	Number int      `json:"number"`
+	Author string   `json:"author,omitempty"`
+	Sender string   `json:"sender,omitempty"`
+	Labels []string `json:"labels"`
+	// body of the comment, issue or pull request
+	Body string `json:"body,omitempty"`
+	// commands parsed from body with aliases resolved
+	Cmds []Command `json:"cmds"`
+}
+
+type Request struct {
+	Version string `json:"version"`
+	Event   Event  `json:"event"`
+}
+
+// Operation is an action on the issue or pull request of the event.
+type Operation struct {
+	Type string `json:"type"`
+	// add_label, remove_label, replace_label
+	Labels []string `json:"labels,omitempty"`
+	// replace_label removes labels with this prefix before adding labels, e.g. "status/"
+	Prefix string `json:"prefix,omitempty"`
+	// assign, unassign, request_reviews, cancel_reviews
+	Users []string `json:"users,omitempty"`
+	// comment
+	Content string `json:"content,omitempty"`
+}
+
+type Response struct {
+	Version    string      `json:"version"`
+	Operations []Operation `json:"operations"`
+}
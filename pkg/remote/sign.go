@@ -0,0 +1,46 @@
+package remote
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const signaturePrefix = "sha256="
+
+// Sign returns the signature of body sent at timestamp(unix seconds),
+// it is HMAC-SHA256 of "{timestamp}.{body}" with the secret.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the signature of body, requests sent more than maxSkew ago are rejected
+// to avoid being replayed. maxSkew <= 0 means no limit.
+func VerifySignature(secret []byte, timestamp string, body []byte, signature string, maxSkew time.Duration) error {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return fmt.Errorf("signature is missing")
+	}
+	if !hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature)) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	if maxSkew > 0 {
+		sec, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Errorf("timestamp is invalid")
+		}
+		skew := time.Since(time.Unix(sec, 0))
+		if skew > maxSkew || skew < -maxSkew {
+			return fmt.Errorf("timestamp is expired")
+		}
+	}
+	return nil
+}
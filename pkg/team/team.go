@@ -0,0 +1,98 @@
+package team
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatedier/freebot/pkg/log"
+)
+
+// MemberLister lists logins of members of the team org/slug.
+type MemberLister interface {
+	ListTeamMembers(ctx context.Context, org, slug string) ([]string, error)
+}
+
+type entry struct {
+	members  map[string]struct{}
+	expireAt time.Time
+}
+
+// Cache caches members of teams for ttl, members are listed again after they expire or are invalidated.
+type Cache struct {
+	lister MemberLister
+	ttl    time.Duration
+
+	entries map[string]*entry
+	mu      sync.Mutex
+}
+
+func NewCache(lister MemberLister, ttl time.Duration) *Cache {
+	return &Cache{
+		lister:  lister,
+		ttl:     ttl,
+		entries: make(map[string]*entry),
+	}
+}
+
+// IsMember returns true if user is a member of the team org/slug, logins and names are case-insensitive.
+// Expired members are still used if listing members fails.
+func (c *Cache) IsMember(ctx context.Context, org, slug, user string) (bool, error) {
+	key := teamKey(org, slug)
+	user = strings.ToLower(user)
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expireAt) {
+		_, isMember := e.members[user]
+		return isMember, nil
+	}
+
+	members, err := c.lister.ListTeamMembers(ctx, org, slug)
+	if err != nil {
+		if ok {
+			log.Warn("list members of team [%s] error: %v, use expired members", key, err)
+			_, isMember := e.members[user]
+			return isMember, nil
+		}
+		return false, err
+	}
+
+	e = &entry{
+		members:  make(map[string]struct{}, len(members)),
+		expireAt: time.Now().Add(c.ttl),
+	}
+	for _, member := range members {
+		e.members[strings.ToLower(member)] = struct{}{}
+	}
+	c.mu.Lock()
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	_, isMember := e.members[user]
+	return isMember, nil
+}
+
+// Invalidate drops cached members of the team org/slug.
+func (c *Cache) Invalidate(org, slug string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, teamKey(org, slug))
+}
+
+// InvalidateOrg drops cached members of all teams in org.
+func (c *Cache) InvalidateOrg(org string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix := strings.ToLower(org) + "/"
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func teamKey(org, slug string) string {
+	return strings.ToLower(org + "/" + slug)
+}
@@ -0,0 +1,205 @@
+package team
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatedier/freebot/pkg/client"
+
+	"github.com/google/go-github/github"
+)
+
+// fakeAPI is a github api server serving members of teams, it fails all requests if failing is set.
+type fakeAPI struct {
+	server *httptest.Server
+
+	// org/slug -> members
+	teams    map[string][]string
+	requests map[string]int
+	failing  bool
+	mu       sync.Mutex
+}
+
+func newFakeAPI(teams map[string][]string) *fakeAPI {
+	api := &fakeAPI{
+		teams:    teams,
+		requests: make(map[string]int),
+	}
+	api.server = httptest.NewServer(http.HandlerFunc(api.serveMembers))
+	return api
+}
+
+// serveMembers serves /orgs/{org}/teams/{slug}/members in one page.
+func (api *fakeAPI) serveMembers(w http.ResponseWriter, r *http.Request) {
+	arrs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(arrs) != 5 || arrs[0] != "orgs" || arrs[2] != "teams" || arrs[4] != "members" {
+		w.WriteHeader(404)
+		return
+	}
+	key := arrs[1] + "/" + arrs[3]
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.requests[key]++
+	if api.failing {
+		w.WriteHeader(502)
+		return
+	}
+	members, ok := api.teams[key]
+	if !ok {
+		w.WriteHeader(404)
+		return
+	}
+	users := make([]*github.User, 0, len(members))
+	for _, member := range members {
+		users = append(users, &github.User{Login: github.String(member)})
+	}
+	json.NewEncoder(w).Encode(users)
+}
+
+func (api *fakeAPI) setFailing(failing bool) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.failing = failing
+}
+
+func (api *fakeAPI) setMembers(key string, members []string) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.teams[key] = members
+}
+
+func (api *fakeAPI) requestCount(key string) int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return api.requests[key]
+}
+
+func (api *fakeAPI) newCache(t *testing.T, ttl time.Duration) *Cache {
+	cli := github.NewClient(nil)
+	u, err := url.Parse(api.server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.BaseURL = u
+	return NewCache(client.NewGithubClient(cli), ttl)
+}
+
+func assertMember(t *testing.T, c *Cache, org, slug, user string, want bool) {
+	t.Helper()
+	isMember, err := c.IsMember(context.Background(), org, slug, user)
+	if err != nil {
+		t.Fatalf("IsMember(%s/%s, %s) error: %v", org, slug, user, err)
+	}
+	if isMember != want {
+		t.Fatalf("IsMember(%s/%s, %s) = %v, want %v", org, slug, user, isMember, want)
+	}
+}
+
+func TestIsMemberCached(t *testing.T) {
+	api := newFakeAPI(map[string][]string{"org/dev": {"alice", "bob"}})
+	defer api.server.Close()
+	c := api.newCache(t, time.Hour)
+
+	assertMember(t, c, "org", "dev", "alice", true)
+	assertMember(t, c, "org", "dev", "bob", true)
+	assertMember(t, c, "org", "dev", "carol", false)
+	if n := api.requestCount("org/dev"); n != 1 {
+		t.Fatalf("members listed %d times, want 1", n)
+	}
+}
+
+func TestIsMemberCaseInsensitive(t *testing.T) {
+	api := newFakeAPI(map[string][]string{"Org/Dev": {"Alice"}})
+	defer api.server.Close()
+	c := api.newCache(t, time.Hour)
+
+	assertMember(t, c, "Org", "Dev", "alice", true)
+	assertMember(t, c, "Org", "Dev", "ALICE", true)
+	// the same team in different cases shares the cache
+	assertMember(t, c, "org", "dev", "Alice", true)
+	if n := api.requestCount("Org/Dev"); n != 1 {
+		t.Fatalf("members listed %d times, want 1", n)
+	}
+}
+
+func TestIsMemberExpired(t *testing.T) {
+	api := newFakeAPI(map[string][]string{"org/dev": {"alice"}})
+	defer api.server.Close()
+	c := api.newCache(t, 50*time.Millisecond)
+
+	assertMember(t, c, "org", "dev", "bob", false)
+	api.setMembers("org/dev", []string{"alice", "bob"})
+	// not expired yet
+	assertMember(t, c, "org", "dev", "bob", false)
+
+	time.Sleep(100 * time.Millisecond)
+	assertMember(t, c, "org", "dev", "bob", true)
+	if n := api.requestCount("org/dev"); n != 2 {
+		t.Fatalf("members listed %d times, want 2", n)
+	}
+}
+
+func TestIsMemberStaleOnError(t *testing.T) {
+	api := newFakeAPI(map[string][]string{"org/dev": {"alice"}})
+	defer api.server.Close()
+	c := api.newCache(t, 50*time.Millisecond)
+
+	assertMember(t, c, "org", "dev", "alice", true)
+	api.setFailing(true)
+	time.Sleep(100 * time.Millisecond)
+
+	// expired members are used if listing fails
+	assertMember(t, c, "org", "dev", "alice", true)
+	assertMember(t, c, "org", "dev", "bob", false)
+
+	// no members to fall back on
+	if _, err := c.IsMember(context.Background(), "org", "qa", "alice"); err == nil {
+		t.Fatal("IsMember should fail if members are never listed")
+	}
+
+	api.setFailing(false)
+	api.setMembers("org/dev", []string{"bob"})
+	assertMember(t, c, "org", "dev", "alice", false)
+	assertMember(t, c, "org", "dev", "bob", true)
+}
+
+func TestInvalidate(t *testing.T) {
+	api := newFakeAPI(map[string][]string{
+		"org/dev":   {"alice"},
+		"org/qa":    {"alice"},
+		"other/dev": {"alice"},
+	})
+	defer api.server.Close()
+	c := api.newCache(t, time.Hour)
+
+	for _, key := range []string{"org/dev", "org/qa", "other/dev"} {
+		arrs := strings.Split(key, "/")
+		assertMember(t, c, arrs[0], arrs[1], "alice", true)
+		api.setMembers(key, []string{"bob"})
+	}
+
+	c.Invalidate("org", "dev")
+	assertMember(t, c, "org", "dev", "alice", false)
+	// other teams are still cached
+	assertMember(t, c, "org", "qa", "alice", true)
+
+	c.InvalidateOrg("ORG")
+	assertMember(t, c, "org", "qa", "alice", false)
+	// teams of other orgs are still cached
+	assertMember(t, c, "other", "dev", "alice", true)
+
+	want := map[string]int{"org/dev": 2, "org/qa": 2, "other/dev": 1}
+	for key, n := range want {
+		if got := api.requestCount(key); got != n {
+			t.Errorf("members of %s listed %d times, want %d", key, got, n)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package watcher
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotSupported = errors.New("watching files is not supported on this platform")
+)
+
+// Watcher notifies changes of files in a directory, changes happened in the debounce interval
+// are merged into one notification which is sent after no more changes are found.
+type Watcher struct {
+	// receives a value after files are changed
+	C <-chan struct{}
+
+	c        chan struct{}
+	debounce time.Duration
+	backend  backend
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// backend sends a value to events for each change found until it is closed.
+type backend interface {
+	Events() <-chan struct{}
+	Close() error
+}
+
+// NewDirWatcher watches files created, removed or modified in dir,
+// ErrNotSupported is returned if the platform has no file system notifications.
+func NewDirWatcher(dir string, debounce time.Duration) (*Watcher, error) {
+	b, err := newBackend(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	c := make(chan struct{}, 1)
+	w := &Watcher{
+		C:        c,
+		c:        c,
+		debounce: debounce,
+		backend:  b,
+		closeCh:  make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *Watcher) run() {
+	var timer <-chan time.Time
+	events := w.backend.Events()
+	for {
+		select {
+		case <-w.closeCh:
+			return
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+			timer = time.After(w.debounce)
+		case <-timer:
+			timer = nil
+			// a pending notification is enough
+			select {
+			case w.c <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (w *Watcher) Close() (err error) {
+	w.closeOnce.Do(func() {
+		close(w.closeCh)
+		err = w.backend.Close()
+	})
+	return
+}
@@ -0,0 +1,111 @@
+//go:build linux
+// +build linux
+
+package watcher
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ATTRIB | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// inotifyBackend watches the directory by inotify, the watch is added again if the directory is
+// removed or moved and then created again.
+type inotifyBackend struct {
+	dir    string
+	file   *os.File
+	fd     int
+	events chan struct{}
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+func newBackend(dir string) (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	if _, err = syscall.InotifyAddWatch(fd, dir, watchMask); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	b := &inotifyBackend{
+		dir: dir,
+		// the fd is non-blocking so reading it is handled by the runtime poller and can be interrupted by Close
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		fd:      fd,
+		events:  make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+	}
+	go b.readLoop()
+	return b, nil
+}
+
+func (b *inotifyBackend) Events() <-chan struct{} {
+	return b.events
+}
+
+func (b *inotifyBackend) readLoop() {
+	defer close(b.events)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		watchRemoved := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				watchRemoved = true
+			}
+			offset += syscall.SizeofInotifyEvent + int(ev.Len)
+		}
+		b.notify()
+
+		if watchRemoved && !b.rewatch() {
+			return
+		}
+	}
+}
+
+// rewatch waits until the directory exists again, returns false if the backend is closed.
+func (b *inotifyBackend) rewatch() bool {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.closeCh:
+			return false
+		case <-ticker.C:
+		}
+		if _, err := syscall.InotifyAddWatch(b.fd, b.dir, watchMask); err == nil {
+			b.notify()
+			return true
+		}
+	}
+}
+
+func (b *inotifyBackend) notify() {
+	select {
+	case b.events <- struct{}{}:
+	default:
+	}
+}
+
+func (b *inotifyBackend) Close() (err error) {
+	b.closeOnce.Do(func() {
+		close(b.closeCh)
+		err = b.file.Close()
+	})
+	return
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package watcher
+
+func newBackend(dir string) (backend, error) {
+	return nil, ErrNotSupported
+}
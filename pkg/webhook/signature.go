@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"strings"
+)
+
+const (
+	HeaderSignature256 = "X-Hub-Signature-256"
+	HeaderSignature    = "X-Hub-Signature"
+)
+
+var (
+	ErrNoSignature      = errors.New("no signature")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// VerifySignature checks the HMAC signature github attached to the payload.
+// sig256 is the value of X-Hub-Signature-256 and sig1 is the value of X-Hub-Signature,
+// the legacy SHA-1 signature is only accepted if allowSHA1 is true.
+func VerifySignature(secret []byte, payload []byte, sig256 string, sig1 string, allowSHA1 bool) error {
+	if sig256 != "" {
+		return verify(sha256.New, "sha256=", secret, payload, sig256)
+	}
+
+	if sig1 != "" && allowSHA1 {
+		return verify(sha1.New, "sha1=", secret, payload, sig1)
+	}
+	return ErrNoSignature
+}
+
+func verify(hashFn func() hash.Hash, prefix string, secret []byte, payload []byte, signature string) error {
+	if !strings.HasPrefix(signature, prefix) {
+		return ErrInvalidSignature
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(hashFn, secret)
+	mac.Write(payload)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -1,10 +1,10 @@
 package assign
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/fatedier/freebot/pkg/client"
+	"github.com/fatedier/freebot/pkg/errutil"
 	"github.com/fatedier/freebot/pkg/event"
 	"github.com/fatedier/freebot/pkg/log"
 	"github.com/fatedier/freebot/pkg/notify"
@@ -20,7 +20,8 @@ var (
 )
 
 func init() {
-	plugin.Register(PluginName, NewAssignPlugin)
+	plugin.RegisterWithPriority(PluginName, NewAssignPlugin, 80)
+	plugin.RegisterExtra(PluginName, Extra{})
 }
 
 type Extra struct {
@@ -44,6 +45,7 @@ func NewAssignPlugin(cli client.ClientInterface, notifier notify.NotifyInterface
 			Actions:          []string{event.ActionCreated},
 			ObjectNeedParams: []int{event.ObjectNeedBody, event.ObjectNeedNumber},
 			Handler:          p.handleCommentEvent,
+			Cmds:             []string{CmdCC, CmdUnCC, CmdAssign, CmdUnAssign},
 		},
 	}
 	options.Handlers = handlerOptions
@@ -52,6 +54,15 @@ func NewAssignPlugin(cli client.ClientInterface, notifier notify.NotifyInterface
 	return p, nil
 }
 
+func (p *AssignPlugin) Commands() []plugin.CommandInfo {
+	return []plugin.CommandInfo{
+		{Name: CmdCC, Args: "@{user1} @{user2}", Description: "request reviews from users"},
+		{Name: CmdUnCC, Args: "@{user1} @{user2}", Description: "cancel review requests of users"},
+		{Name: CmdAssign, Args: "@{user1} @{user2}", Description: "assign users"},
+		{Name: CmdUnAssign, Args: "@{user1} @{user2}", Description: "unassign users"},
+	}
+}
+
 func (p *AssignPlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 	msg, _ := ctx.Object.Body()
 	number, _ := ctx.Object.Number()
@@ -92,7 +103,7 @@ func (p *AssignPlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 		})
 		if partialErr != nil {
 			log.Warn("plugin [%s] do cc operation error: %v", PluginName, partialErr)
-			err = fmt.Errorf("%v;%v", err, partialErr)
+			err = errutil.Append(err, partialErr)
 		}
 	}
 
@@ -105,7 +116,7 @@ func (p *AssignPlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 		})
 		if partialErr != nil {
 			log.Warn("plugin [%s] do uncc operation error: %v", PluginName, partialErr)
-			err = fmt.Errorf("%v;%v", err, partialErr)
+			err = errutil.Append(err, partialErr)
 		}
 	}
 
@@ -118,7 +129,7 @@ func (p *AssignPlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 		})
 		if partialErr != nil {
 			log.Warn("plugin [%s] do assign operation error: %v", PluginName, partialErr)
-			err = fmt.Errorf("%v;%v", err, partialErr)
+			err = errutil.Append(err, partialErr)
 		}
 	}
 
@@ -131,7 +142,7 @@ func (p *AssignPlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 		})
 		if partialErr != nil {
 			log.Warn("plugin [%s] do unassign operation error: %v", PluginName, partialErr)
-			err = fmt.Errorf("%v;%v", err, partialErr)
+			err = errutil.Append(err, partialErr)
 		}
 	}
 	return
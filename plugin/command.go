@@ -1,7 +1,10 @@
 package plugin
 
 import (
+	"fmt"
 	"strings"
+
+	"github.com/fatedier/freebot/pkg/config"
 )
 
 type Command struct {
@@ -44,3 +47,62 @@ func (p *BasePlugin) ParseCmdsFromMsg(msg string, onlyOneArg bool) []*Command {
 	}
 	return cmds
 }
+
+// CommandInfo describes a command provided by a plugin, it is shown by /help.
+type CommandInfo struct {
+	Name string
+	// usage of arguments, e.g. "@{user1} @{user2}"
+	Args        string
+	Description string
+	// preconditions of this command, plugin preconditions are checked too
+	Preconditions []config.Precondition
+}
+
+// CommandsInterface is implemented by plugins providing commands, commands may depend on extra conf.
+type CommandsInterface interface {
+	Commands() []CommandInfo
+}
+
+// PluginsAwareInterface is implemented by plugins which need other plugins of the same repo,
+// plugins are set after all of them are created.
+type PluginsAwareInterface interface {
+	SetPlugins(plugins []Plugin)
+}
+
+// DescribePreconditions returns a readable form of preconditions, any one of them should be satisfied,
+// e.g. "role owner and label lgtm/approved, or the author".
+func DescribePreconditions(preconditions []config.Precondition) string {
+	descs := make([]string, 0, len(preconditions))
+	for _, pre := range preconditions {
+		desc := DescribePrecondition(pre)
+		if desc == "" {
+			// an empty precondition is always satisfied
+			return ""
+		}
+		descs = append(descs, desc)
+	}
+	return strings.Join(descs, ", or ")
+}
+
+// DescribePrecondition returns a readable form of all conditions in precondition, empty if there is none.
+func DescribePrecondition(pre config.Precondition) string {
+	parts := make([]string, 0)
+	if pre.IsAuthor {
+		parts = append(parts, "the author")
+	}
+	if len(pre.RequiredRoles) > 0 {
+		parts = append(parts, fmt.Sprintf("%s %s", plural(len(pre.RequiredRoles), "role", "roles"),
+			strings.Join(pre.RequiredRoles, " and ")))
+	}
+	if len(pre.RequiredLabels) > 0 {
+		parts = append(parts, fmt.Sprintf("%s %s", plural(len(pre.RequiredLabels), "label", "labels"),
+			strings.Join(pre.RequiredLabels, " and ")))
+	}
+	for _, prefix := range pre.RequiredLabelPrefix {
+		parts = append(parts, fmt.Sprintf("a label with prefix %s", prefix))
+	}
+	for _, m := range pre.MatchLabels {
+		parts = append(parts, fmt.Sprintf("%s/{name} labels matched by %s/{name}", m.BasePrefix, m.TargetPrefix))
+	}
+	return strings.Join(parts, " and ")
+}
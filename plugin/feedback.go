@@ -0,0 +1,197 @@
+package plugin
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fatedier/freebot/pkg/client"
+	"github.com/fatedier/freebot/pkg/config"
+	"github.com/fatedier/freebot/pkg/event"
+	"github.com/fatedier/freebot/pkg/log"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	defaultFeedbackIntervalS = 60
+	// limiter drops expired entries when it has more entries than this
+	maxFeedbackLimiterEntries = 1024
+)
+
+// ReasonError is an error with a concise reason which can be shown to users, e.g. "requires role owner".
+// Error() is not changed so logs keep the details.
+type ReasonError struct {
+	Err    error
+	Reason string
+}
+
+func NewReasonError(reason string, err error) *ReasonError {
+	return &ReasonError{
+		Err:    err,
+		Reason: reason,
+	}
+}
+
+func (e *ReasonError) Error() string {
+	return e.Err.Error()
+}
+
+// ErrorReason returns the reason of err which can be shown to users, empty if err doesn't carry one.
+func ErrorReason(err error) string {
+	switch v := err.(type) {
+	case *ReasonError:
+		return v.Reason
+	case *PreconditionError:
+		return ErrorReason(v.Err)
+	}
+	return ""
+}
+
+// FeedbackLimiter limits how often feedback is sent to the same issue or pull request,
+// it is shared by all repos.
+type FeedbackLimiter struct {
+	// key -> time before which no feedback is sent
+	until map[string]time.Time
+	mu    sync.Mutex
+}
+
+func NewFeedbackLimiter() *FeedbackLimiter {
+	return &FeedbackLimiter{
+		until: make(map[string]time.Time),
+	}
+}
+
+// Allow returns true if no feedback is sent to key in the last interval, and marks key as sent.
+func (l *FeedbackLimiter) Allow(key string, interval time.Duration) bool {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until, ok := l.until[key]; ok && now.Before(until) {
+		return false
+	}
+	l.until[key] = now.Add(interval)
+
+	if len(l.until) > maxFeedbackLimiterEntries {
+		for k, until := range l.until {
+			if !now.Before(until) {
+				delete(l.until, k)
+			}
+		}
+	}
+	return true
+}
+
+// Feedback tells users why their commands are rejected or fail, with a comment or a reaction.
+type Feedback struct {
+	cli      client.ClientInterface
+	mode     string
+	interval time.Duration
+	limiter  *FeedbackLimiter
+}
+
+func NewFeedback(cli client.ClientInterface, options config.FeedbackOptions, limiter *FeedbackLimiter) *Feedback {
+	mode := options.Mode
+	if mode == "" {
+		mode = config.FeedbackOff
+	}
+	intervalS := options.IntervalS
+	if intervalS <= 0 {
+		intervalS = defaultFeedbackIntervalS
+	}
+	return &Feedback{
+		cli:      cli,
+		mode:     mode,
+		interval: time.Duration(intervalS) * time.Second,
+		limiter:  limiter,
+	}
+}
+
+// Send replies to the comment containing cmd, reason is used if not empty.
+// Errors are only logged because feedback should not fail the plugin.
+func (f *Feedback) Send(ctx *event.EventContext, cmd string, reason string) {
+	if f.mode == config.FeedbackOff {
+		return
+	}
+	number, ok := ctx.Object.Number()
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s#%d", ctx.Owner, ctx.Repo, number)
+	if f.limiter != nil && !f.limiter.Allow(key, f.interval) {
+		log.Debug("[%s] feedback of [/%s] is rate limited", key, cmd)
+		return
+	}
+
+	var op interface{}
+	switch f.mode {
+	case config.FeedbackReaction:
+		reaction := &client.AddReactionOperation{
+			Owner:   ctx.Owner,
+			Repo:    ctx.Repo,
+			Content: "confused",
+		}
+		switch v := ctx.Object.Payload().(type) {
+		case *github.IssueCommentEvent:
+			reaction.CommentID = v.GetComment().GetID()
+		case *github.PullRequestReviewCommentEvent:
+			reaction.CommentID = v.GetComment().GetID()
+			reaction.ReviewComment = true
+		}
+		if reaction.CommentID == 0 {
+			return
+		}
+		op = reaction
+	case config.FeedbackComment:
+		op = &client.AddIssueCommentOperation{
+			Owner:   ctx.Owner,
+			Repo:    ctx.Repo,
+			Number:  number,
+			Content: feedbackContent(ctx, cmd, reason),
+		}
+	default:
+		return
+	}
+
+	if err := f.cli.DoOperation(ctx.Ctx, op); err != nil {
+		log.Warn("[%s] send feedback of [/%s] error: %v", key, cmd, err)
+	}
+}
+
+func feedbackContent(ctx *event.EventContext, cmd string, reason string) string {
+	content := fmt.Sprintf("`/%s` is not done", strings.Replace(cmd, "`", "", -1))
+	if user, ok := ctx.Object.SenderUser(); ok && user != "" {
+		content = "@" + user + " " + content
+	}
+	if reason == "" {
+		return content + " because of an internal error, please contact the maintainers."
+	}
+	return content + ": " + reason + "."
+}
+
+// joinReasons joins reasons of preconditions, any one of which is enough.
+func joinReasons(errs []error) string {
+	reasons := make([]string, 0, len(errs))
+	for _, err := range errs {
+		reason := ErrorReason(err)
+		if reason == "" {
+			return ""
+		}
+		if !stringContains(reasons, reason) {
+			reasons = append(reasons, reason)
+		}
+	}
+	return strings.Join(reasons, ", or ")
+}
+
+func stringContains(strs []string, s string) bool {
+	for _, v := range strs {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
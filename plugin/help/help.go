@@ -0,0 +1,227 @@
+package help
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatedier/freebot/pkg/client"
+	"github.com/fatedier/freebot/pkg/config"
+	"github.com/fatedier/freebot/pkg/event"
+	"github.com/fatedier/freebot/pkg/notify"
+	"github.com/fatedier/freebot/plugin"
+)
+
+var (
+	PluginName = "help"
+	CmdHelp    = "help"
+)
+
+func init() {
+	plugin.RegisterWithPriority(PluginName, NewHelpPlugin, 0)
+	plugin.RegisterExtra(PluginName, Extra{})
+}
+
+type Extra struct {
+}
+
+// command is a command of a plugin with the plugin preconditions.
+type command struct {
+	plugin.CommandInfo
+
+	plugin              string
+	pluginPreconditions []config.Precondition
+}
+
+type HelpPlugin struct {
+	*plugin.BasePlugin
+
+	cli      client.ClientInterface
+	notifier notify.NotifyInterface
+	plugins  []plugin.Plugin
+}
+
+func NewHelpPlugin(cli client.ClientInterface, notifier notify.NotifyInterface, options plugin.PluginOptions) (plugin.Plugin, error) {
+	p := &HelpPlugin{
+		cli:      cli,
+		notifier: notifier,
+		plugins:  make([]plugin.Plugin, 0),
+	}
+
+	handlerOptions := []plugin.HandlerOptions{
+		plugin.HandlerOptions{
+			Events:           []string{event.EvIssueComment, event.EvPullRequestReviewComment},
+			Actions:          []string{event.ActionCreated},
+			ObjectNeedParams: []int{event.ObjectNeedBody, event.ObjectNeedNumber},
+			Handler:          p.handleCommentEvent,
+			Cmds:             []string{CmdHelp},
+		},
+	}
+	options.Handlers = handlerOptions
+
+	p.BasePlugin = plugin.NewBasePlugin(PluginName, options)
+	return p, nil
+}
+
+// SetPlugins is called after all plugins of the repo are created, commands are collected from them.
+func (p *HelpPlugin) SetPlugins(plugins []plugin.Plugin) {
+	p.plugins = plugins
+}
+
+func (p *HelpPlugin) Commands() []plugin.CommandInfo {
+	return []plugin.CommandInfo{
+		{Name: CmdHelp, Args: "[command]", Description: "show commands enabled in this repo, or details of one command"},
+	}
+}
+
+func (p *HelpPlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
+	msg, _ := ctx.Object.Body()
+	number, _ := ctx.Object.Number()
+
+	for _, cmd := range p.ParseCmdsFromMsg(msg, false) {
+		if p.ParseCmdAlias(cmd.Name) != CmdHelp {
+			continue
+		}
+
+		var content string
+		if len(cmd.Args) > 0 {
+			content = p.commandHelp(strings.TrimLeft(cmd.Args[0], "/"))
+		} else {
+			content = p.allHelp()
+		}
+		return p.cli.DoOperation(ctx.Ctx, &client.AddIssueCommentOperation{
+			Owner:   ctx.Owner,
+			Repo:    ctx.Repo,
+			Number:  number,
+			Content: content,
+		})
+	}
+	return
+}
+
+// commands returns commands of all plugins ordered by name, commands with the same name keep the order of plugins.
+func (p *HelpPlugin) commands() []command {
+	cmds := make([]command, 0)
+	for _, pl := range p.plugins {
+		v, ok := pl.(plugin.CommandsInterface)
+		if !ok {
+			continue
+		}
+		var pluginPreconditions []config.Precondition
+		if base, ok := pl.(interface{ GetPreconditions() []config.Precondition }); ok {
+			pluginPreconditions = base.GetPreconditions()
+		}
+		for _, info := range v.Commands() {
+			cmds = append(cmds, command{
+				CommandInfo:         info,
+				plugin:              pl.Name(),
+				pluginPreconditions: pluginPreconditions,
+			})
+		}
+	}
+	sort.SliceStable(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
+
+// aliases returns alias.cmds entries of the command.
+func (p *HelpPlugin) aliases(name string) []string {
+	out := make([]string, 0)
+	for alias, cmd := range p.GetAlias().Cmds {
+		if cmd == name {
+			out = append(out, alias)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (p *HelpPlugin) allHelp() string {
+	cmds := p.commands()
+	if len(cmds) == 0 {
+		return "No commands are enabled in this repo."
+	}
+
+	var b strings.Builder
+	b.WriteString("Commands enabled in this repo, comment `/help {command}` for details:\n\n")
+	b.WriteString("| Command | Aliases | Description | Who can run |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, cmd := range cmds {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", usage(cmd.CommandInfo), formatAliases(p.aliases(cmd.Name)),
+			escapeCell(cmd.Description), escapeCell(whoCanRun(cmd)))
+	}
+	return b.String()
+}
+
+func (p *HelpPlugin) commandHelp(name string) string {
+	name = p.ParseCmdAlias(name)
+	found := make([]command, 0)
+	for _, cmd := range p.commands() {
+		if cmd.Name == name {
+			found = append(found, cmd)
+		}
+	}
+	if len(found) == 0 {
+		return fmt.Sprintf("Command `/%s` is not enabled in this repo, comment `/help` to list all commands.",
+			strings.Replace(name, "`", "", -1))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### /%s\n\n", name)
+	fmt.Fprintf(&b, "Plugin: %s\n\n", found[0].plugin)
+	if aliases := p.aliases(name); len(aliases) > 0 {
+		fmt.Fprintf(&b, "Aliases: %s\n\n", formatAliases(aliases))
+	}
+	b.WriteString("| Usage | Description | Who can run |\n")
+	b.WriteString("| --- | --- | --- |\n")
+	for _, cmd := range found {
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", usage(cmd.CommandInfo), escapeCell(cmd.Description), escapeCell(whoCanRun(cmd)))
+	}
+	return b.String()
+}
+
+// whoCanRun describes plugin preconditions and command preconditions, both of them should be satisfied.
+func whoCanRun(cmd command) string {
+	descs := make([]string, 0, 2)
+	for _, preconditions := range [][]config.Precondition{cmd.pluginPreconditions, cmd.Preconditions} {
+		desc := plugin.DescribePreconditions(preconditions)
+		if desc != "" {
+			descs = append(descs, desc)
+		}
+	}
+	switch len(descs) {
+	case 0:
+		return "anyone"
+	case 1:
+		return descs[0]
+	}
+	for i, desc := range descs {
+		if strings.Contains(desc, ", or ") {
+			descs[i] = "(" + desc + ")"
+		}
+	}
+	return strings.Join(descs, " and ")
+}
+
+func usage(info plugin.CommandInfo) string {
+	text := "/" + info.Name
+	if info.Args != "" {
+		text += " " + info.Args
+	}
+	return "`" + escapeCell(text) + "`"
+}
+
+func formatAliases(aliases []string) string {
+	out := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		out = append(out, "`/"+escapeCell(alias)+"`")
+	}
+	return strings.Join(out, ", ")
+}
+
+// escapeCell escapes characters breaking markdown table cells.
+func escapeCell(s string) string {
+	s = strings.Replace(s, "\n", " ", -1)
+	return strings.Replace(s, "|", "\\|", -1)
+}
@@ -1,6 +1,8 @@
 package label
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/fatedier/freebot/pkg/client"
@@ -36,7 +38,8 @@ var (
 )
 
 func init() {
-	plugin.Register(PluginName, NewLabelPlugin)
+	plugin.RegisterWithPriority(PluginName, NewLabelPlugin, 100)
+	plugin.RegisterExtra(PluginName, Extra{})
 }
 
 type LabelStatus struct {
@@ -67,6 +70,7 @@ func NewLabelPlugin(cli client.ClientInterface, notifier notify.NotifyInterface,
 			Actions:          []string{event.ActionCreated},
 			ObjectNeedParams: []int{event.ObjectNeedBody, event.ObjectNeedNumber, event.ObjectNeedLabels},
 			Handler:          p.handleCommentEvent,
+			IsCmd:            p.isLabelCmd,
 		},
 	}
 	options.Handlers = handlerOptions
@@ -80,6 +84,15 @@ func NewLabelPlugin(cli client.ClientInterface, notifier notify.NotifyInterface,
 	return p, nil
 }
 
+// isLabelCmd returns true if name is a label prefix in extra, or the prefix with PluginRemoveCmdPrefix.
+func (p *LablePlugin) isLabelCmd(name string) bool {
+	if _, ok := p.extra[name]; ok {
+		return true
+	}
+	_, ok := p.extra[strings.TrimPrefix(name, PluginRemoveCmdPrefix)]
+	return ok && strings.HasPrefix(name, PluginRemoveCmdPrefix)
+}
+
 func (p *LablePlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 	log.Debug("lable plugin extra config is: %v", p.extra)
 
@@ -102,7 +115,7 @@ func (p *LablePlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 				err = p.CheckPreconditions(ctx, p.extra[cmd.Name].AddPreconditions)
 				if err != nil {
 					log.Warn("all preconditions check failed: %v", err)
-					return
+					return &plugin.PreconditionError{Err: err}
 				}
 
 				err = p.cli.DoOperation(ctx.Ctx, &client.AddLabelOperation{
@@ -126,7 +139,7 @@ func (p *LablePlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 					err = p.CheckPreconditions(ctx, p.extra[trimName].RemovePreconditions)
 					if err != nil {
 						log.Warn("all preconditions check failed: %v", err)
-						return
+						return &plugin.PreconditionError{Err: err}
 					}
 
 					err = p.cli.DoOperation(ctx.Ctx, &client.RemoveLabelOperation{
@@ -154,3 +167,29 @@ func stringContains(strs []string, s string) bool {
 	}
 	return false
 }
+
+func (p *LablePlugin) Commands() []plugin.CommandInfo {
+	names := make([]string, 0, len(p.extra))
+	for name := range p.extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cmds := make([]plugin.CommandInfo, 0, 2*len(names))
+	for _, name := range names {
+		status := p.extra[name]
+		args := "{" + strings.Join(status.Labels, "|") + "}"
+		cmds = append(cmds, plugin.CommandInfo{
+			Name:          name,
+			Args:          args,
+			Description:   fmt.Sprintf("add label %s/{label}", name),
+			Preconditions: status.AddPreconditions,
+		}, plugin.CommandInfo{
+			Name:          PluginRemoveCmdPrefix + name,
+			Args:          args,
+			Description:   fmt.Sprintf("remove label %s/{label}", name),
+			Preconditions: status.RemovePreconditions,
+		})
+	}
+	return cmds
+}
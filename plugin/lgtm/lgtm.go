@@ -18,7 +18,8 @@ var (
 )
 
 func init() {
-	plugin.Register(PluginName, NewLGTMPlugin)
+	plugin.RegisterWithPriority(PluginName, NewLGTMPlugin, 90)
+	plugin.RegisterExtra(PluginName, Extra{})
 }
 
 type TargetLabel struct {
@@ -31,8 +32,9 @@ type Extra struct {
 	TargetLabels    []TargetLabel `json:"target_labels"`
 }
 
+// Complete uses "module" as base_label_prefix if it is not set.
 func (ex *Extra) Complete() {
-	if ex.BaseLabelPrefix != "" {
+	if ex.BaseLabelPrefix == "" {
 		ex.BaseLabelPrefix = "module"
 	}
 	if ex.TargetLabels == nil {
@@ -40,6 +42,35 @@ func (ex *Extra) Complete() {
 	}
 }
 
+func (ex *Extra) Validate(options plugin.PluginOptions) []error {
+	errs := make([]error, 0)
+	if len(ex.TargetLabels) == 0 {
+		return errs
+	}
+
+	hasBaseLabel := false
+	for label := range options.LabelRoles {
+		if strings.HasPrefix(label, ex.BaseLabelPrefix+"/") {
+			hasBaseLabel = true
+			break
+		}
+	}
+	if !hasBaseLabel {
+		errs = append(errs, fmt.Errorf("no label with base_label_prefix [%s] in label_roles", ex.BaseLabelPrefix))
+	}
+
+	roles := options.LabelRoles.Roles()
+	for i, t := range ex.TargetLabels {
+		if t.TargetPrefix == "" {
+			errs = append(errs, fmt.Errorf("target_labels[%d]: target_prefix is empty", i))
+		}
+		if _, ok := roles[t.Role]; !ok {
+			errs = append(errs, fmt.Errorf("target_labels[%d]: role [%s] not found in label_roles", i, t.Role))
+		}
+	}
+	return errs
+}
+
 type LGTMPlugin struct {
 	*plugin.BasePlugin
 
@@ -74,6 +105,7 @@ func NewLGTMPlugin(cli client.ClientInterface, notifier notify.NotifyInterface,
 			ObjectNeedParams: []int{event.ObjectNeedBody, event.ObjectNeedNumber, event.ObjectNeedLabels,
 				event.ObjectNeedCommentAuthor, event.ObjectNeedAuthor},
 			Handler: p.handleCommentEvent,
+			Cmds:    []string{CmdLGTM, CmdUnLGTM},
 		},
 	}
 	options.Handlers = handlerOptions
@@ -138,7 +170,7 @@ func (p *LGTMPlugin) handlePullRequestSynchronizeEvent(ctx *event.EventContext)
 func (p *LGTMPlugin) handleLGTM(ctx *event.EventContext, lgtmUser string) (err error) {
 	author, _ := ctx.Object.Author()
 	if author == lgtmUser {
-		return fmt.Errorf("lgtm is not valid for author")
+		return plugin.NewReasonError("the author can't lgtm their own pull request", fmt.Errorf("lgtm is not valid for author"))
 	}
 
 	number, _ := ctx.Object.Number()
@@ -173,11 +205,8 @@ func (p *LGTMPlugin) handleLGTM(ctx *event.EventContext, lgtmUser string) (err e
 				continue
 			}
 
-			for _, user := range users {
-				if lgtmUser == user {
-					targetLabels = append(targetLabels, t.TargetPrefix+"/"+sub)
-					break
-				}
+			if p.IsRoleMember(ctx.Ctx, lgtmUser, users) {
+				targetLabels = append(targetLabels, t.TargetPrefix+"/"+sub)
 			}
 		}
 	}
@@ -197,3 +226,21 @@ func (p *LGTMPlugin) handleLGTM(ctx *event.EventContext, lgtmUser string) (err e
 	}
 	return
 }
+
+// Commands doesn't contain unlgtm because it is not supported yet.
+func (p *LGTMPlugin) Commands() []plugin.CommandInfo {
+	if len(p.extra.TargetLabels) == 0 {
+		return nil
+	}
+	targets := make([]string, 0, len(p.extra.TargetLabels))
+	for _, t := range p.extra.TargetLabels {
+		targets = append(targets, fmt.Sprintf("%s/{name} if you are in role %s", t.TargetPrefix, t.Role))
+	}
+	return []plugin.CommandInfo{
+		{
+			Name: CmdLGTM,
+			Description: fmt.Sprintf("for each %s/{name} label, add %s of the label in label_roles, the author can't lgtm",
+				p.extra.BaseLabelPrefix, strings.Join(targets, " or ")),
+		},
+	}
+}
@@ -14,7 +14,8 @@ var (
 )
 
 func init() {
-	plugin.Register(PluginName, NewLifecyclePlugin)
+	plugin.RegisterWithPriority(PluginName, NewLifecyclePlugin, 70)
+	plugin.RegisterExtra(PluginName, Extra{})
 }
 
 type Extra struct {
@@ -38,6 +39,7 @@ func NewLifecyclePlugin(cli client.ClientInterface, notifier notify.NotifyInterf
 			Actions:          []string{event.ActionCreated},
 			ObjectNeedParams: []int{event.ObjectNeedBody, event.ObjectNeedNumber},
 			Handler:          p.handleCommentEvent,
+			Cmds:             []string{CmdClose, CmdReopen},
 		},
 	}
 	options.Handlers = handlerOptions
@@ -46,6 +48,13 @@ func NewLifecyclePlugin(cli client.ClientInterface, notifier notify.NotifyInterf
 	return p, nil
 }
 
+func (p *LifecyclePlugin) Commands() []plugin.CommandInfo {
+	return []plugin.CommandInfo{
+		{Name: CmdClose, Description: "close the issue or pull request"},
+		{Name: CmdReopen, Description: "reopen the issue or pull request"},
+	}
+}
+
 func (p *LifecyclePlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 	msg, _ := ctx.Object.Body()
 	number, _ := ctx.Object.Number()
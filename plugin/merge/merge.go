@@ -15,7 +15,9 @@ var (
 )
 
 func init() {
-	plugin.Register(PluginName, NewMergePlugin)
+	// run after plugins changing labels so preconditions are checked with the latest labels
+	plugin.RegisterWithPriority(PluginName, NewMergePlugin, 10)
+	plugin.RegisterExtra(PluginName, Extra{})
 }
 
 type Extra struct {
@@ -40,6 +42,7 @@ func NewMergePlugin(cli client.ClientInterface, notifier notify.NotifyInterface,
 			Actions:          []string{event.ActionCreated},
 			ObjectNeedParams: []int{event.ObjectNeedBody, event.ObjectNeedNumber, event.ObjectNeedLabels},
 			Handler:          p.handleCommentEvent,
+			Cmds:             []string{CmdMerge},
 		},
 	}
 	options.Handlers = handlerOptions
@@ -48,6 +51,12 @@ func NewMergePlugin(cli client.ClientInterface, notifier notify.NotifyInterface,
 	return p, nil
 }
 
+func (p *MergePlugin) Commands() []plugin.CommandInfo {
+	return []plugin.CommandInfo{
+		{Name: CmdMerge, Description: "merge the pull request if it is mergeable"},
+	}
+}
+
 func (p *MergePlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 	msg, _ := ctx.Object.Body()
 	number, _ := ctx.Object.Number()
@@ -64,7 +73,8 @@ func (p *MergePlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 			}
 
 			if !mergeable {
-				err = fmt.Errorf("[%s] pull request not mergeable", PluginName)
+				err = plugin.NewReasonError("the pull request is not mergeable",
+					fmt.Errorf("[%s] pull request not mergeable", PluginName))
 				return
 			}
 
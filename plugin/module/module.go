@@ -18,7 +18,8 @@ var (
 )
 
 func init() {
-	plugin.Register(PluginName, NewModulePlugin)
+	plugin.RegisterWithPriority(PluginName, NewModulePlugin, 100)
+	plugin.RegisterExtra(PluginName, Extra{})
 }
 
 type ModuleMap struct {
@@ -30,6 +31,8 @@ type Extra struct {
 	LablePrefix        string            `json:"label_prefix"`
 	EnableCommentRoles []string          `json:"enable_comment_roles"`
 	FilePrefixMap      map[string]string `json:"file_prefix_map"`
+	// keyword in issue title -> module
+	IssueKeywordMap map[string]string `json:"issue_keyword_map"`
 
 	moduleMaps []*ModuleMap `json:"-"`
 }
@@ -53,6 +56,20 @@ func (ex *Extra) Complete() {
 	if ex.EnableCommentRoles == nil {
 		ex.EnableCommentRoles = make([]string, 0)
 	}
+	if ex.IssueKeywordMap == nil {
+		ex.IssueKeywordMap = make(map[string]string)
+	}
+}
+
+func (ex *Extra) Validate(options plugin.PluginOptions) []error {
+	errs := make([]error, 0)
+	roles := options.LabelRoles.Roles()
+	for i, role := range ex.EnableCommentRoles {
+		if _, ok := roles[role]; !ok {
+			errs = append(errs, fmt.Errorf("enable_comment_roles[%d]: role [%s] not found in label_roles", i, role))
+		}
+	}
+	return errs
 }
 
 type ModulePlugin struct {
@@ -75,6 +92,12 @@ func NewModulePlugin(cli client.ClientInterface, notifier notify.NotifyInterface
 			ObjectNeedParams: []int{event.ObjectNeedNumber},
 			Handler:          p.handlePullRequestEvent,
 		},
+		plugin.HandlerOptions{
+			Events:           []string{event.EvIssues},
+			Actions:          []string{event.ActionOpened, event.ActionEdited},
+			ObjectNeedParams: []int{event.ObjectNeedNumber, event.ObjectNeedTitle, event.ObjectNeedLabels},
+			Handler:          p.handleIssuesEvent,
+		},
 	}
 	options.Handlers = handlerOptions
 
@@ -177,3 +200,52 @@ func (p *ModulePlugin) handlePullRequestEvent(ctx *event.EventContext) (err erro
 	}
 	return
 }
+
+// handleIssuesEvent adds module labels according to keywords in issue title,
+// labels attached before won't be removed.
+func (p *ModulePlugin) handleIssuesEvent(ctx *event.EventContext) (err error) {
+	if len(p.extra.IssueKeywordMap) == 0 {
+		return nil
+	}
+
+	number, _ := ctx.Object.Number()
+	title, _ := ctx.Object.Title()
+	originLabels, _ := ctx.Object.Labels()
+	originLabelsMap := make(map[string]struct{})
+	for _, l := range originLabels {
+		originLabelsMap[l] = struct{}{}
+	}
+
+	title = strings.ToLower(title)
+	labelsMap := make(map[string]struct{})
+	for keyword, moduleName := range p.extra.IssueKeywordMap {
+		if !strings.Contains(title, strings.ToLower(keyword)) {
+			continue
+		}
+
+		label := p.extra.LablePrefix + "/" + moduleName
+		if _, ok := originLabelsMap[label]; !ok {
+			labelsMap[label] = struct{}{}
+		}
+	}
+	if len(labelsMap) == 0 {
+		return nil
+	}
+
+	labels := make([]string, 0, len(labelsMap))
+	for name, _ := range labelsMap {
+		labels = append(labels, name)
+	}
+	sort.Strings(labels)
+	err = p.cli.DoOperation(ctx.Ctx, &client.AddLabelOperation{
+		Owner:  ctx.Owner,
+		Repo:   ctx.Repo,
+		Number: number,
+		Labels: labels,
+	})
+	if err != nil {
+		return
+	}
+	log.Debug("[%d] add label %v", number, labels)
+	return
+}
@@ -15,6 +15,9 @@ import (
 const (
 	NotifyCheckRunComplete   = "check_run_complete"
 	NotifyCheckSuiteComplete = "check_suite_complete"
+
+	// issues events are named as issues_{action}, e.g. issues_opened, issues_assigned
+	NotifyIssuesPrefix = "issues_"
 )
 
 var (
@@ -23,7 +26,8 @@ var (
 )
 
 func init() {
-	plugin.Register(PluginName, NewNotifyPlugin)
+	plugin.RegisterWithPriority(PluginName, NewNotifyPlugin, 50)
+	plugin.RegisterExtra(PluginName, Extra{})
 }
 
 type EventNotifyConf struct {
@@ -86,11 +90,20 @@ func NewNotifyPlugin(cli client.ClientInterface, notifier notify.NotifyInterface
 			ObjectNeedParams: []int{event.ObjectNeedCheckEvent},
 			Handler:          p.handleCheckRunEvent,
 		},
+		plugin.HandlerOptions{
+			Events: []string{event.EvIssues},
+			Actions: []string{event.ActionOpened, event.ActionReopened, event.ActionClosed, event.ActionAssigned,
+				event.ActionLabeled, event.ActionTransferred},
+			ObjectNeedParams: []int{event.ObjectNeedAction, event.ObjectNeedAuthor, event.ObjectNeedSenderUser,
+				event.ObjectNeedTitle, event.ObjectNeedIssueHTMLURL},
+			Handler: p.handleIssuesEvent,
+		},
 		plugin.HandlerOptions{
 			Events:           []string{event.EvIssueComment, event.EvPullRequest, event.EvPullRequestReviewComment},
 			Actions:          []string{event.ActionCreated},
 			ObjectNeedParams: []int{event.ObjectNeedBody, event.ObjectNeedCommentAuthor, event.ObjectNeedIssueHTMLURL},
 			Handler:          p.handleCommentEvent,
+			Cmds:             []string{CmdPing},
 		},
 	}
 	options.Handlers = handlerOptions
@@ -117,7 +130,7 @@ func (p *NotifyPlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 	err = p.CheckPreconditions(ctx, p.extra.Ping.Preconditions)
 	if err != nil {
 		log.Warn("preconditions check failed: %v", err)
-		return
+		return &plugin.PreconditionError{Err: err}
 	}
 
 	cmds := p.ParseCmdsFromMsg(msg, false)
@@ -221,6 +234,39 @@ func (p *NotifyPlugin) handleCheckRunEvent(ctx *event.EventContext) (err error)
 	return
 }
 
+// handleIssuesEvent notifies the assignee for assigned action and the author for others,
+// default user is notified if they are not in users.
+func (p *NotifyPlugin) handleIssuesEvent(ctx *event.EventContext) (err error) {
+	action, _ := ctx.Object.Action()
+	eventName := NotifyIssuesPrefix + action
+	conf, ok := p.extra.Events[eventName]
+	if !ok {
+		return
+	}
+
+	user, _ := ctx.Object.Author()
+	if action == event.ActionAssigned {
+		if user, ok = ctx.Object.Assignee(); !ok {
+			return
+		}
+	}
+	sender, _ := ctx.Object.SenderUser()
+	title, _ := ctx.Object.Title()
+	issueHTMLURL, _ := ctx.Object.IssueHTMLURL()
+
+	notifyOption, err := p.getNotifyOption(eventName, user, conf)
+	if err != nil {
+		log.Warn("%v", err)
+		return err
+	}
+
+	content := fmt.Sprintf("[%s/%s] issue %s by [%s]\n", ctx.Owner, ctx.Repo, action, sender)
+	content += fmt.Sprintf("Title [%s]\n%s", title, issueHTMLURL)
+	log.Debug("issue [%s] [%s], send notify", title, action)
+	err = p.notifier.Send(ctx.Ctx, notifyOption, content)
+	return
+}
+
 func (p *NotifyPlugin) getNotifyOption(eventName string, user string, conf *EventNotifyConf) (notifyOption *notify.NotifyOptions, err error) {
 	conf, ok := p.extra.Events[eventName]
 	if !ok {
@@ -243,3 +289,17 @@ func (p *NotifyPlugin) getNotifyOption(eventName string, user string, conf *Even
 	}
 	return
 }
+
+func (p *NotifyPlugin) Commands() []plugin.CommandInfo {
+	if p.extra.Ping.Disable {
+		return nil
+	}
+	return []plugin.CommandInfo{
+		{
+			Name:          CmdPing,
+			Args:          "@{user} [message]",
+			Description:   "send a notification to the user with the link of this page",
+			Preconditions: p.extra.Ping.Preconditions,
+		},
+	}
+}
@@ -1,21 +1,30 @@
 package plugin
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/fatedier/freebot/pkg/client"
 	"github.com/fatedier/freebot/pkg/config"
+	"github.com/fatedier/freebot/pkg/errutil"
 	"github.com/fatedier/freebot/pkg/event"
 	"github.com/fatedier/freebot/pkg/log"
 	"github.com/fatedier/freebot/pkg/notify"
 )
 
-var creators map[string]CreatorFn
+var (
+	creators          map[string]CreatorFn
+	defaultPriorities map[string]int
+	extraTypes        map[string]reflect.Type
+)
 
 func init() {
 	creators = make(map[string]CreatorFn)
+	defaultPriorities = make(map[string]int)
+	extraTypes = make(map[string]reflect.Type)
 }
 
 type CreatorFn func(cli client.ClientInterface, notifier notify.NotifyInterface, options PluginOptions) (Plugin, error)
@@ -26,6 +35,43 @@ func Register(name string, fn CreatorFn) {
 	creators[name] = fn
 }
 
+// RegisterWithPriority registers the plugin with its default priority,
+// plugins with higher priority handle the same event earlier.
+func RegisterWithPriority(name string, fn CreatorFn, priority int) {
+	creators[name] = fn
+	defaultPriorities[name] = priority
+}
+
+// DefaultPriority returns the default priority of the plugin, it is 0 if not specified when registered.
+func DefaultPriority(name string) int {
+	return defaultPriorities[name]
+}
+
+func IsRegistered(name string) bool {
+	_, ok := creators[name]
+	return ok
+}
+
+// RegisterExtra registers the type of extra conf of the plugin, so it can be checked without creating the plugin.
+func RegisterExtra(name string, extra interface{}) {
+	extraTypes[name] = reflect.TypeOf(extra)
+}
+
+// NewExtra returns a pointer to a zero value of the extra type registered by the plugin.
+func NewExtra(name string) (interface{}, bool) {
+	t, ok := extraTypes[name]
+	if !ok {
+		return nil, false
+	}
+	return reflect.New(t).Interface(), true
+}
+
+// ExtraValidator is implemented by extra conf of plugins which can check itself with repo conf,
+// it is called after Complete if the extra has this method.
+type ExtraValidator interface {
+	Validate(options PluginOptions) []error
+}
+
 func Create(cli client.ClientInterface, notifier notify.NotifyInterface, name string, options PluginOptions) (p Plugin, err error) {
 	if fn, ok := creators[name]; ok {
 		p, err = fn(cli, notifier, options)
@@ -40,6 +86,11 @@ type HandlerOptions struct {
 	Actions          []string // empty means support all
 	ObjectNeedParams []int
 	Handler          Handler
+
+	// commands handled, users are told why these commands fail if feedback is enabled
+	Cmds []string
+	// checks commands which are only known after extra is parsed, e.g. labels defined in extra
+	IsCmd func(name string) bool
 }
 
 type Plugin interface {
@@ -47,6 +98,36 @@ type Plugin interface {
 	HandleEvent(ctx *event.EventContext) (notSupport bool, err error)
 }
 
+// DryRunInterface is implemented by plugins embedding BasePlugin.
+type DryRunInterface interface {
+	IsDryRun() bool
+}
+
+// PreconditionError is returned by HandleEvent if preconditions of the plugin or the command are not satisfied.
+type PreconditionError struct {
+	Err error
+}
+
+func (e *PreconditionError) Error() string {
+	return e.Err.Error()
+}
+
+func IsPreconditionError(err error) bool {
+	_, ok := err.(*PreconditionError)
+	return ok
+}
+
+// ParsedExtraInterface is implemented by plugins embedding BasePlugin, ParsedExtra returns nil
+// if the plugin doesn't parse extra conf into its own struct.
+type ParsedExtraInterface interface {
+	ParsedExtra() interface{}
+}
+
+// TeamResolver checks members of github teams referred by roles, see config.TeamRolePrefix.
+type TeamResolver interface {
+	IsMember(ctx context.Context, org, slug, user string) (bool, error)
+}
+
 type PluginOptions struct {
 	Owner         string
 	Repo          string
@@ -55,6 +136,12 @@ type PluginOptions struct {
 	LabelRoles    config.LabelRoles
 	Preconditions []config.Precondition
 	Extra         interface{}
+	// nil if team roles are not supported
+	Teams TeamResolver
+	// operations are recorded instead of being done, the client passed to the creator is already wrapped
+	DryRun bool
+	// nil if feedback is not supported
+	Feedback *Feedback
 
 	// filled by plugin
 	Handlers []HandlerOptions
@@ -84,6 +171,11 @@ type BasePlugin struct {
 	labelRoles    config.LabelRoles
 	preconditions []config.Precondition
 	extra         interface{}
+	// pointer passed to UnmarshalTo, nil if extra is not parsed
+	parsedExtra interface{}
+	teams       TeamResolver
+	dryRun      bool
+	feedback    *Feedback
 
 	handlers []HandlerOptions
 }
@@ -98,6 +190,9 @@ func NewBasePlugin(name string, options PluginOptions) *BasePlugin {
 		labelRoles:    options.LabelRoles,
 		preconditions: options.Preconditions,
 		extra:         options.Extra,
+		teams:         options.Teams,
+		dryRun:        options.DryRun,
+		feedback:      options.Feedback,
 		handlers:      options.Handlers,
 	}
 }
@@ -114,6 +209,11 @@ func (p *BasePlugin) GetRepo() string {
 	return p.repo
 }
 
+// IsDryRun returns true if operations of the plugin are recorded instead of being done.
+func (p *BasePlugin) IsDryRun() bool {
+	return p.dryRun
+}
+
 func (p *BasePlugin) GetAlias() config.AliasOptions {
 	return p.alias
 }
@@ -143,10 +243,16 @@ func (p *BasePlugin) UnmarshalTo(v interface{}) error {
 	if err = json.Unmarshal(buf, &v); err != nil {
 		return fmt.Errorf("[%s] extra conf parse failed", p.name)
 	}
-	log.Info("[%s/%s] [%s] %v", p.owner, p.repo, p.name, p.extra)
+	p.parsedExtra = v
+	log.Info("[%s/%s] [%s] %v", p.owner, p.repo, p.name, config.RedactValue(p.extra))
 	return nil
 }
 
+// ParsedExtra returns the extra conf parsed by UnmarshalTo, changes made by the plugin after parsing are included.
+func (p *BasePlugin) ParsedExtra() interface{} {
+	return p.parsedExtra
+}
+
 func (p *BasePlugin) IsSupported(ctx *event.EventContext, handlerOptions HandlerOptions) bool {
 	if len(handlerOptions.Events) > 0 {
 		if !p.IsSupportedEvent(ctx.Type, handlerOptions) {
@@ -220,27 +326,50 @@ func (p *BasePlugin) ParseUserAlias(str string) string {
 	return str
 }
 
-func (p *BasePlugin) IsSpecifiedRoles(user string, roles []string) bool {
+func (p *BasePlugin) IsSpecifiedRoles(ctx context.Context, user string, roles []string) bool {
 	for _, role := range roles {
 		users, ok := p.roles[role]
 		if !ok {
 			return false
 		}
 
-		find := false
-		for _, t := range users {
-			if t == user {
-				find = true
-				break
-			}
-		}
-		if !find {
+		if !p.IsRoleMember(ctx, user, users) {
 			return false
 		}
 	}
 	return true
 }
 
+// IsRoleMember returns true if user is in entries of a role, entries may be users or teams.
+// Users are checked first so teams are only resolved if necessary.
+func (p *BasePlugin) IsRoleMember(ctx context.Context, user string, entries []string) bool {
+	teams := make([]string, 0)
+	for _, t := range entries {
+		if t == user {
+			return true
+		}
+		if strings.HasPrefix(t, config.TeamRolePrefix) {
+			teams = append(teams, t)
+		}
+	}
+
+	for _, t := range teams {
+		org, slug, ok := config.ParseTeamRole(t)
+		if !ok || p.teams == nil {
+			continue
+		}
+		isMember, err := p.teams.IsMember(ctx, org, slug, user)
+		if err != nil {
+			log.Warn("[%s/%s] check member of team [%s/%s] error: %v", p.owner, p.repo, org, slug, err)
+			continue
+		}
+		if isMember {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *BasePlugin) CheckPluginPreconditions(ctx *event.EventContext) (err error) {
 	return p.CheckPreconditions(ctx, p.preconditions)
 }
@@ -251,14 +380,19 @@ func (p *BasePlugin) CheckPreconditions(ctx *event.EventContext, preconditions [
 	}
 
 	var partialErr error
+	errs := make([]error, 0, len(preconditions))
 	for _, pre := range preconditions {
 		partialErr = p.CheckPrecondition(ctx, pre)
 		if partialErr == nil {
 			return nil
 		} else {
-			err = fmt.Errorf("%v; %v", err, partialErr)
+			err = errutil.Append(err, partialErr)
+			errs = append(errs, partialErr)
 		}
 	}
+	if reason := joinReasons(errs); reason != "" {
+		err = NewReasonError(reason, err)
+	}
 	return
 }
 
@@ -306,7 +440,8 @@ func (p *BasePlugin) CheckIsAuthor(ctx *event.EventContext) error {
 	sender, ok2 := ctx.Object.SenderUser()
 	isAuthor := author == sender
 	if !ok || !ok2 || !isAuthor {
-		return fmt.Errorf("check is author failed, author [%s], sender [%s]", author, sender)
+		return NewReasonError("only the author can do this",
+			fmt.Errorf("check is author failed, author [%s], sender [%s]", author, sender))
 	}
 	return nil
 }
@@ -317,8 +452,9 @@ func (p *BasePlugin) CheckRequiredRoles(ctx *event.EventContext, roles []string)
 		return fmt.Errorf("check required roles failed, get sender failed")
 	}
 
-	if !p.IsSpecifiedRoles(sender, roles) {
-		return fmt.Errorf("check required roles failed: %s not in roles %v", sender, roles)
+	if !p.IsSpecifiedRoles(ctx.Ctx, sender, roles) {
+		return NewReasonError(fmt.Sprintf("requires %s %s", plural(len(roles), "role", "roles"), strings.Join(roles, " and ")),
+			fmt.Errorf("check required roles failed: %s not in roles %v", sender, roles))
 	}
 	return nil
 }
@@ -336,7 +472,8 @@ func (p *BasePlugin) CheckRequiredLabels(ctx *event.EventContext, labels []strin
 
 	for _, label := range labels {
 		if _, ok := allMap[label]; !ok {
-			return fmt.Errorf("check required labels failed: %s doesn't exist", label)
+			return NewReasonError(fmt.Sprintf("missing label %s", label),
+				fmt.Errorf("check required labels failed: %s doesn't exist", label))
 		}
 	}
 	return nil
@@ -357,7 +494,8 @@ func (p *BasePlugin) CheckRequiredLabelPrefix(ctx *event.EventContext, prefix []
 			}
 		}
 		if !hasOne {
-			return fmt.Errorf("check required label prefix failed: %s prefix label not found", prefixStr)
+			return NewReasonError(fmt.Sprintf("missing label with prefix %s", prefixStr),
+				fmt.Errorf("check required label prefix failed: %s prefix label not found", prefixStr))
 		}
 	}
 	return nil
@@ -385,7 +523,8 @@ func (p *BasePlugin) CheckMatchLabels(ctx *event.EventContext, matchLabels []con
 			if conf.BasePrefix == base {
 				target := conf.TargetPrefix + "/" + sub
 				if _, ok := labelsMap[target]; !ok {
-					return fmt.Errorf("check match labels failed: %s has not required label %s", name, conf.TargetPrefix+"/"+sub)
+					return NewReasonError(fmt.Sprintf("label %s requires label %s", name, target),
+						fmt.Errorf("check match labels failed: %s has not required label %s", name, conf.TargetPrefix+"/"+sub))
 				}
 			}
 		}
@@ -436,6 +575,12 @@ func (p *BasePlugin) HandleEvent(ctx *event.EventContext) (notSupport bool, err
 			case event.ObjectNeedCheckEvent:
 				_, ok = ctx.Object.CheckEvent()
 				paramName = "check event"
+			case event.ObjectNeedTitle:
+				_, ok = ctx.Object.Title()
+				paramName = "title"
+			case event.ObjectNeedAssignee:
+				_, ok = ctx.Object.Assignee()
+				paramName = "assignee"
 			default:
 				log.Error("error ObjectNeedParams setting")
 				continue
@@ -451,6 +596,8 @@ func (p *BasePlugin) HandleEvent(ctx *event.EventContext) (notSupport bool, err
 		if !meetPreconditions {
 			err = p.CheckPluginPreconditions(ctx)
 			if err != nil {
+				err = &PreconditionError{Err: err}
+				p.sendFeedback(ctx, handlerOptions, err)
 				return
 			}
 			meetPreconditions = true
@@ -458,6 +605,7 @@ func (p *BasePlugin) HandleEvent(ctx *event.EventContext) (notSupport bool, err
 
 		err = handlerOptions.Handler(ctx)
 		if err != nil {
+			p.sendFeedback(ctx, handlerOptions, err)
 			return
 		}
 	}
@@ -468,3 +616,33 @@ func (p *BasePlugin) HandleEvent(ctx *event.EventContext) (notSupport bool, err
 	}
 	return false, nil
 }
+
+// sendFeedback tells the user why the command in the comment failed, nothing is sent if the event is not
+// a new comment or it doesn't contain commands of the handler.
+func (p *BasePlugin) sendFeedback(ctx *event.EventContext, handlerOptions HandlerOptions, err error) {
+	if p.feedback == nil || (len(handlerOptions.Cmds) == 0 && handlerOptions.IsCmd == nil) {
+		return
+	}
+	if ctx.Type != event.EvIssueComment && ctx.Type != event.EvPullRequestReviewComment {
+		return
+	}
+	if action, _ := ctx.Object.Action(); action != event.ActionCreated {
+		return
+	}
+
+	msg, _ := ctx.Object.Body()
+	for _, cmd := range p.ParseCmdsFromMsg(msg, false) {
+		name := p.ParseCmdAlias(cmd.Name)
+		if stringContains(handlerOptions.Cmds, name) || (handlerOptions.IsCmd != nil && handlerOptions.IsCmd(name)) {
+			p.feedback.Send(ctx, strings.Join(append([]string{cmd.Name}, cmd.Args...), " "), ErrorReason(err))
+			return
+		}
+	}
+}
+
+func plural(n int, singular string, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
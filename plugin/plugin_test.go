@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fatedier/freebot/pkg/config"
+)
+
+// fakeTeams resolves members of teams from a map and records teams checked.
+type fakeTeams struct {
+	members map[string][]string
+	checked []string
+}
+
+func (f *fakeTeams) IsMember(ctx context.Context, org, slug, user string) (bool, error) {
+	f.checked = append(f.checked, org+"/"+slug)
+	for _, member := range f.members[org+"/"+slug] {
+		if member == user {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func newRolesPlugin(roles config.RoleOptions, teams TeamResolver) *BasePlugin {
+	options := PluginOptions{}
+	options.Complete("owner", "repo", config.AliasOptions{}, roles, nil, nil, nil)
+	options.Teams = teams
+	return NewBasePlugin("test", options)
+}
+
+func TestIsSpecifiedRolesPlainUsers(t *testing.T) {
+	roles := config.RoleOptions{
+		"owner":    {"alice"},
+		"reviewer": {"alice", "bob"},
+	}
+	tests := []struct {
+		user  string
+		roles []string
+		want  bool
+	}{
+		{user: "alice", roles: []string{"owner"}, want: true},
+		{user: "alice", roles: []string{"owner", "reviewer"}, want: true},
+		{user: "bob", roles: []string{"reviewer"}, want: true},
+		{user: "bob", roles: []string{"owner", "reviewer"}, want: false},
+		{user: "carol", roles: []string{"reviewer"}, want: false},
+		{user: "alice", roles: []string{"unknown"}, want: false},
+	}
+
+	// results of plain user entries don't depend on team support
+	teams := &fakeTeams{}
+	for _, p := range []*BasePlugin{newRolesPlugin(roles, nil), newRolesPlugin(roles, teams)} {
+		for _, tt := range tests {
+			if got := p.IsSpecifiedRoles(context.Background(), tt.user, tt.roles); got != tt.want {
+				t.Errorf("IsSpecifiedRoles(%s, %v) = %v, want %v, teams supported: %v", tt.user, tt.roles, got, tt.want, p.teams != nil)
+			}
+		}
+	}
+	if len(teams.checked) != 0 {
+		t.Errorf("teams %v are checked for roles without teams", teams.checked)
+	}
+}
+
+func TestIsRoleMemberTeams(t *testing.T) {
+	teams := &fakeTeams{members: map[string][]string{"org/dev": {"bob"}}}
+	p := newRolesPlugin(nil, teams)
+	entries := []string{"alice", "team:org/dev"}
+
+	if !p.IsRoleMember(context.Background(), "alice", entries) {
+		t.Error("alice should be a member")
+	}
+	if len(teams.checked) != 0 {
+		t.Errorf("teams %v are checked although the user is listed", teams.checked)
+	}
+	if !p.IsRoleMember(context.Background(), "bob", entries) {
+		t.Error("bob should be a member of team org/dev")
+	}
+	if p.IsRoleMember(context.Background(), "carol", entries) {
+		t.Error("carol should not be a member")
+	}
+
+	// team entries are ignored if teams are not supported
+	if newRolesPlugin(nil, nil).IsRoleMember(context.Background(), "bob", entries) {
+		t.Error("bob should not be a member if teams are not supported")
+	}
+}
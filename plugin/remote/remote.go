@@ -0,0 +1,353 @@
+package remote
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatedier/freebot/pkg/client"
+	"github.com/fatedier/freebot/pkg/config"
+	"github.com/fatedier/freebot/pkg/errutil"
+	"github.com/fatedier/freebot/pkg/event"
+	"github.com/fatedier/freebot/pkg/log"
+	"github.com/fatedier/freebot/pkg/notify"
+	"github.com/fatedier/freebot/pkg/remote"
+	"github.com/fatedier/freebot/plugin"
+)
+
+var (
+	PluginName = "remote"
+)
+
+func init() {
+	plugin.RegisterWithPriority(PluginName, NewRemotePlugin, 30)
+	plugin.RegisterExtra(PluginName, Extra{})
+}
+
+type Endpoint struct {
+	URL string `json:"url"`
+	// requests are signed with secret if it is not empty
+	Secret   string `json:"secret"`
+	TimeoutS int    `json:"timeout_s"`
+
+	// comments containing these commands are sent
+	Cmds []string `json:"cmds"`
+	// events sent, formatted as {event} or {event}/{action}, e.g. issues/opened, pull_request
+	Events []string `json:"events"`
+
+	// operation type -> preconditions, only operations listed here can be run
+	Operations map[string][]config.Precondition `json:"operations"`
+}
+
+type Extra struct {
+	Endpoints map[string]*Endpoint `json:"endpoints"`
+}
+
+func (ex *Extra) Complete() {
+	if ex.Endpoints == nil {
+		ex.Endpoints = make(map[string]*Endpoint)
+	}
+	for _, endpoint := range ex.Endpoints {
+		if endpoint != nil && endpoint.TimeoutS <= 0 {
+			endpoint.TimeoutS = 10
+		}
+	}
+}
+
+func (ex *Extra) Validate(options plugin.PluginOptions) []error {
+	errs := make([]error, 0)
+	for _, name := range sortedKeys(ex.Endpoints) {
+		endpoint := ex.Endpoints[name]
+		if endpoint == nil {
+			errs = append(errs, fmt.Errorf("endpoints[%q]: endpoint is empty", name))
+			continue
+		}
+		if u, err := url.Parse(endpoint.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("endpoints[%q].url: should be a http or https url", name))
+		}
+		for _, ev := range endpoint.Events {
+			if arrs := strings.Split(ev, "/"); len(arrs) > 2 || arrs[0] == "" || (len(arrs) == 2 && arrs[1] == "") {
+				errs = append(errs, fmt.Errorf("endpoints[%q].events: [%s] should be {event} or {event}/{action}", name, ev))
+			}
+		}
+		for opType := range endpoint.Operations {
+			if !stringContains(remote.OperationTypes, opType) {
+				errs = append(errs, fmt.Errorf("endpoints[%q].operations: unknown operation type [%s]", name, opType))
+			}
+		}
+	}
+	return errs
+}
+
+type RemotePlugin struct {
+	*plugin.BasePlugin
+
+	extra    Extra
+	cli      client.ClientInterface
+	notifier notify.NotifyInterface
+	clients  map[string]*remote.Client
+}
+
+func NewRemotePlugin(cli client.ClientInterface, notifier notify.NotifyInterface, options plugin.PluginOptions) (plugin.Plugin, error) {
+	p := &RemotePlugin{
+		cli:      cli,
+		notifier: notifier,
+		clients:  make(map[string]*remote.Client),
+	}
+
+	handlerOptions := []plugin.HandlerOptions{
+		plugin.HandlerOptions{
+			Events: []string{event.EvIssues, event.EvIssueComment, event.EvPullRequest,
+				event.EvPullRequestReview, event.EvPullRequestReviewComment},
+			ObjectNeedParams: []int{event.ObjectNeedNumber},
+			Handler:          p.handleEvent,
+			IsCmd:            p.isEndpointCmd,
+		},
+	}
+	options.Handlers = handlerOptions
+
+	p.BasePlugin = plugin.NewBasePlugin(PluginName, options)
+
+	err := p.UnmarshalTo(&p.extra)
+	if err != nil {
+		return nil, err
+	}
+	p.extra.Complete()
+	if errs := p.extra.Validate(options); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	for name, endpoint := range p.extra.Endpoints {
+		p.clients[name] = &remote.Client{
+			URL:        endpoint.URL,
+			Secret:     endpoint.Secret,
+			Timeout:    time.Duration(endpoint.TimeoutS) * time.Second,
+			HTTPClient: http.DefaultClient,
+		}
+	}
+	return p, nil
+}
+
+// handleEvent sends the event to all endpoints interested in it, failures of endpoints don't affect others.
+// PreconditionError is returned if operations of some endpoints are not allowed and no other errors happen.
+func (p *RemotePlugin) handleEvent(ctx *event.EventContext) (err error) {
+	var preconditionErr error
+	ev := p.buildEvent(ctx)
+	for _, name := range sortedKeys(p.extra.Endpoints) {
+		endpoint := p.extra.Endpoints[name]
+		if !p.shouldSend(endpoint, ev) {
+			continue
+		}
+
+		partialErr := p.callEndpoint(ctx, name, endpoint, ev)
+		if partialErr == nil {
+			continue
+		}
+		isPreconditionErr := plugin.IsPreconditionError(partialErr)
+		partialErr = fmt.Errorf("endpoint [%s]: %v", name, partialErr)
+		if isPreconditionErr {
+			preconditionErr = errutil.Append(preconditionErr, partialErr)
+		} else {
+			log.Warn("[%s/%s] %v", ctx.Owner, ctx.Repo, partialErr)
+			err = errutil.Append(err, partialErr)
+		}
+	}
+
+	if err == nil && preconditionErr != nil {
+		return &plugin.PreconditionError{Err: preconditionErr}
+	}
+	return errutil.Append(err, preconditionErr)
+}
+
+// buildEvent normalizes the event, commands are only parsed from comments.
+func (p *RemotePlugin) buildEvent(ctx *event.EventContext) *remote.Event {
+	ev := &remote.Event{
+		Type:   ctx.Type,
+		Owner:  ctx.Owner,
+		Repo:   ctx.Repo,
+		Labels: make([]string, 0),
+		Cmds:   make([]remote.Command, 0),
+	}
+	ev.Action, _ = ctx.Object.Action()
+	ev.Number, _ = ctx.Object.Number()
+	ev.Author, _ = ctx.Object.Author()
+	ev.Sender, _ = ctx.Object.SenderUser()
+	ev.Body, _ = ctx.Object.Body()
+	if labels, ok := ctx.Object.Labels(); ok {
+		ev.Labels = labels
+	}
+
+	if isCommentEvent(ev) {
+		for _, cmd := range p.ParseCmdsFromMsg(ev.Body, false) {
+			ev.Cmds = append(ev.Cmds, remote.Command{
+				Name: p.ParseCmdAlias(cmd.Name),
+				Args: cmd.Args,
+			})
+		}
+	}
+	return ev
+}
+
+func isCommentEvent(ev *remote.Event) bool {
+	return (ev.Type == event.EvIssueComment || ev.Type == event.EvPullRequestReviewComment) &&
+		ev.Action == event.ActionCreated
+}
+
+// isEndpointCmd returns true if name is in cmds of any endpoint.
+func (p *RemotePlugin) isEndpointCmd(name string) bool {
+	for _, endpoint := range p.extra.Endpoints {
+		if endpoint != nil && stringContains(endpoint.Cmds, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RemotePlugin) shouldSend(endpoint *Endpoint, ev *remote.Event) bool {
+	for _, v := range endpoint.Events {
+		if v == ev.Type || v == ev.Type+"/"+ev.Action {
+			return true
+		}
+	}
+	for _, cmd := range ev.Cmds {
+		if stringContains(endpoint.Cmds, cmd.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// callEndpoint sends the event and runs operations replied, no operation is run if any of them is invalid
+// or not allowed by preconditions.
+func (p *RemotePlugin) callEndpoint(ctx *event.EventContext, name string, endpoint *Endpoint, ev *remote.Event) error {
+	ops, err := p.clients[name].Call(ctx.Ctx, ev)
+	if err != nil {
+		return err
+	}
+
+	clientOps := make([]interface{}, 0, len(ops))
+	for i, op := range ops {
+		preconditions, ok := endpoint.Operations[op.Type]
+		if !ok {
+			return fmt.Errorf("operations[%d]: operation [%s] is not allowed", i, op.Type)
+		}
+		converted, err := p.toClientOperations(ctx, ev.Number, op)
+		if err != nil {
+			return fmt.Errorf("operations[%d]: %v", i, err)
+		}
+		if err = p.CheckPreconditions(ctx, preconditions); err != nil {
+			return &plugin.PreconditionError{Err: fmt.Errorf("operation [%s]: %v", op.Type, err)}
+		}
+		clientOps = append(clientOps, converted...)
+	}
+
+	for _, clientOp := range clientOps {
+		if err = p.cli.DoOperation(ctx.Ctx, clientOp); err != nil {
+			return err
+		}
+	}
+	log.Debug("[%s/%s] remote endpoint [%s] ran %d operations", ctx.Owner, ctx.Repo, name, len(clientOps))
+	return nil
+}
+
+// toClientOperations converts the operation on the issue or pull request of the event,
+// remove_label with more than one label is converted to one operation per label.
+func (p *RemotePlugin) toClientOperations(ctx *event.EventContext, number int, op remote.Operation) ([]interface{}, error) {
+	if op.Type == remote.OpRemoveLabel {
+		if len(op.Labels) == 0 {
+			return nil, fmt.Errorf("labels are empty")
+		}
+		out := make([]interface{}, 0, len(op.Labels))
+		for _, label := range op.Labels {
+			out = append(out, &client.RemoveLabelOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number, Label: label})
+		}
+		return out, nil
+	}
+
+	clientOp, err := p.toClientOperation(ctx, number, op)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{clientOp}, nil
+}
+
+// toClientOperation converts the operation on the issue or pull request of the event.
+func (p *RemotePlugin) toClientOperation(ctx *event.EventContext, number int, op remote.Operation) (interface{}, error) {
+	switch op.Type {
+	case remote.OpAddLabel, remote.OpReplaceLabel:
+		if len(op.Labels) == 0 {
+			return nil, fmt.Errorf("labels are empty")
+		}
+	case remote.OpAssign, remote.OpUnassign, remote.OpRequestReviews, remote.OpCancelReviews:
+		if len(op.Users) == 0 {
+			return nil, fmt.Errorf("users are empty")
+		}
+	case remote.OpComment:
+		if op.Content == "" {
+			return nil, fmt.Errorf("content is empty")
+		}
+	}
+
+	switch op.Type {
+	case remote.OpAddLabel:
+		return &client.AddLabelOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number, Labels: op.Labels}, nil
+	case remote.OpReplaceLabel:
+		if op.Prefix == "" {
+			return nil, fmt.Errorf("prefix is empty")
+		}
+		return &client.ReplaceLabelOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number,
+			ReplaceLabelPrefix: op.Prefix, Labels: op.Labels}, nil
+	case remote.OpAssign:
+		return &client.AddAssignOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number, Assignees: op.Users}, nil
+	case remote.OpUnassign:
+		return &client.RemoveAssignOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number, Assignees: op.Users}, nil
+	case remote.OpRequestReviews:
+		return &client.RequestReviewsOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number, Reviewers: op.Users}, nil
+	case remote.OpCancelReviews:
+		return &client.RequestReviewsCancelOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number, CancelReviewers: op.Users}, nil
+	case remote.OpComment:
+		return &client.AddIssueCommentOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number, Content: op.Content}, nil
+	case remote.OpClose:
+		return &client.CloseOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number, Object: ctx.Object}, nil
+	case remote.OpReopen:
+		return &client.ReopenOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number, Object: ctx.Object}, nil
+	case remote.OpMerge:
+		return &client.MergeOperation{Owner: ctx.Owner, Repo: ctx.Repo, Number: number}, nil
+	}
+	return nil, fmt.Errorf("unknown operation type [%s]", op.Type)
+}
+
+func (p *RemotePlugin) Commands() []plugin.CommandInfo {
+	cmds := make([]plugin.CommandInfo, 0)
+	for _, name := range sortedKeys(p.extra.Endpoints) {
+		for _, cmd := range p.extra.Endpoints[name].Cmds {
+			cmds = append(cmds, plugin.CommandInfo{
+				Name:        cmd,
+				Args:        "[args]",
+				Description: fmt.Sprintf("handled by remote endpoint %s", name),
+			})
+		}
+	}
+	return cmds
+}
+
+func sortedKeys(m map[string]*Endpoint) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func stringContains(strs []string, s string) bool {
+	for _, v := range strs {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,444 @@
+package rules
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/fatedier/freebot/pkg/client"
+	"github.com/fatedier/freebot/pkg/config"
+	"github.com/fatedier/freebot/pkg/errutil"
+	"github.com/fatedier/freebot/pkg/event"
+	"github.com/fatedier/freebot/pkg/log"
+	"github.com/fatedier/freebot/pkg/notify"
+	"github.com/fatedier/freebot/plugin"
+
+	"github.com/google/go-github/github"
+)
+
+var (
+	PluginName = "rules"
+)
+
+func init() {
+	plugin.RegisterWithPriority(PluginName, NewRulesPlugin, 20)
+	plugin.RegisterExtra(PluginName, Extra{})
+}
+
+type Matcher struct {
+	// formatted as {event} or {event}/{action}, e.g. pull_request/labeled, issues
+	Events []string `json:"events"`
+	// labels added or removed by labeled and unlabeled actions, matches any label if empty
+	Labels []string `json:"labels"`
+}
+
+type ReplaceLabelAction struct {
+	Prefix string   `json:"prefix"`
+	Labels []string `json:"labels"`
+}
+
+type RequestReviewsAction struct {
+	Users []string `json:"users"`
+	// users of these roles are requested, teams in roles are skipped
+	Roles []string `json:"roles"`
+}
+
+type NotifyAction struct {
+	notify.NotifyOptions
+	Content string `json:"content"`
+}
+
+// Action is one of the fields, comment and notify content are templates.
+type Action struct {
+	AddLabels      []string              `json:"add_labels"`
+	ReplaceLabel   *ReplaceLabelAction   `json:"replace_label"`
+	RequestReviews *RequestReviewsAction `json:"request_reviews"`
+	Comment        string                `json:"comment"`
+	Notify         *NotifyAction         `json:"notify"`
+}
+
+type Rule struct {
+	Name string                `json:"name"`
+	When Matcher               `json:"when"`
+	If   []config.Precondition `json:"if"`
+	Then []Action              `json:"then"`
+}
+
+type Extra struct {
+	Rules []Rule `json:"rules"`
+}
+
+func (ex *Extra) Complete() {
+	if ex.Rules == nil {
+		ex.Rules = make([]Rule, 0)
+	}
+}
+
+func (ex *Extra) Validate(options plugin.PluginOptions) []error {
+	errs := make([]error, 0)
+	for i, rule := range ex.Rules {
+		prefix := fmt.Sprintf("rules[%d]", i)
+		if rule.Name != "" {
+			prefix = fmt.Sprintf("rules[%q]", rule.Name)
+		}
+
+		if len(rule.When.Events) == 0 {
+			errs = append(errs, fmt.Errorf("%s.when.events: events are empty", prefix))
+		}
+		for _, ev := range rule.When.Events {
+			if arrs := strings.Split(ev, "/"); len(arrs) > 2 || arrs[0] == "" || (len(arrs) == 2 && arrs[1] == "") {
+				errs = append(errs, fmt.Errorf("%s.when.events: [%s] should be {event} or {event}/{action}", prefix, ev))
+			}
+		}
+		if len(rule.Then) == 0 {
+			errs = append(errs, fmt.Errorf("%s.then: actions are empty", prefix))
+		}
+		for j, action := range rule.Then {
+			for _, err := range validateAction(action, options) {
+				errs = append(errs, fmt.Errorf("%s.then[%d]: %v", prefix, j, err))
+			}
+		}
+	}
+	return errs
+}
+
+func validateAction(action Action, options plugin.PluginOptions) []error {
+	errs := make([]error, 0)
+	count := 0
+	if len(action.AddLabels) > 0 {
+		count++
+	}
+	if action.ReplaceLabel != nil {
+		count++
+		if action.ReplaceLabel.Prefix == "" {
+			errs = append(errs, fmt.Errorf("replace_label.prefix is empty"))
+		}
+	}
+	if action.RequestReviews != nil {
+		count++
+		if len(action.RequestReviews.Users) == 0 && len(action.RequestReviews.Roles) == 0 {
+			errs = append(errs, fmt.Errorf("request_reviews: users and roles are empty"))
+		}
+		for _, role := range action.RequestReviews.Roles {
+			if _, ok := options.Roles[role]; !ok {
+				errs = append(errs, fmt.Errorf("request_reviews.roles: role [%s] is not defined", role))
+			}
+		}
+	}
+	if action.Comment != "" {
+		count++
+		if _, err := newTemplate(action.Comment); err != nil {
+			errs = append(errs, fmt.Errorf("comment: %v", err))
+		}
+	}
+	if action.Notify != nil {
+		count++
+		if action.Notify.Content == "" {
+			errs = append(errs, fmt.Errorf("notify.content is empty"))
+		} else if _, err := newTemplate(action.Notify.Content); err != nil {
+			errs = append(errs, fmt.Errorf("notify.content: %v", err))
+		}
+	}
+	if count != 1 {
+		errs = append(errs, fmt.Errorf("exactly one of add_labels, replace_label, request_reviews, comment and notify should be set"))
+	}
+	return errs
+}
+
+// TemplateData contains fields of the event which can be used in templates, e.g. {{.Author}}.
+// Fields not carried by the event are empty.
+type TemplateData struct {
+	Event  string
+	Action string
+	Owner  string
+	Repo   string
+	Number int
+	Title  string
+	URL    string
+	Author string
+	Sender string
+	// label added or removed by labeled and unlabeled actions
+	Label  string
+	Labels []string
+	Body   string
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	// mention returns "@user1 @user2"
+	"mention": func(users []string) string {
+		out := make([]string, 0, len(users))
+		for _, user := range users {
+			out = append(out, "@"+user)
+		}
+		return strings.Join(out, " ")
+	},
+	// role is replaced when executing, it is defined here so templates can be parsed
+	"role": func(name string) []string { return nil },
+}
+
+func newTemplate(text string) (*template.Template, error) {
+	return template.New("").Funcs(templateFuncs).Parse(text)
+}
+
+type RulesPlugin struct {
+	*plugin.BasePlugin
+
+	extra    Extra
+	cli      client.ClientInterface
+	notifier notify.NotifyInterface
+}
+
+func NewRulesPlugin(cli client.ClientInterface, notifier notify.NotifyInterface, options plugin.PluginOptions) (plugin.Plugin, error) {
+	p := &RulesPlugin{
+		cli:      cli,
+		notifier: notifier,
+	}
+
+	handlerOptions := []plugin.HandlerOptions{
+		plugin.HandlerOptions{
+			Events: []string{event.EvIssues, event.EvIssueComment, event.EvPullRequest,
+				event.EvPullRequestReview, event.EvPullRequestReviewComment},
+			ObjectNeedParams: []int{event.ObjectNeedNumber},
+			Handler:          p.handleEvent,
+		},
+	}
+	options.Handlers = handlerOptions
+
+	p.BasePlugin = plugin.NewBasePlugin(PluginName, options)
+
+	err := p.UnmarshalTo(&p.extra)
+	if err != nil {
+		return nil, err
+	}
+	p.extra.Complete()
+	if errs := p.extra.Validate(options); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return p, nil
+}
+
+// handleEvent runs actions of all rules matched in order, rules whose conditions are not satisfied are skipped.
+// Errors of a rule don't stop the following rules.
+func (p *RulesPlugin) handleEvent(ctx *event.EventContext) (err error) {
+	data := p.buildTemplateData(ctx)
+	for i, rule := range p.extra.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i)
+		}
+		if !p.match(rule.When, data) {
+			continue
+		}
+		if partialErr := p.CheckPreconditions(ctx, rule.If); partialErr != nil {
+			log.Debug("[%s/%s] rule [%s] conditions not satisfied: %v", ctx.Owner, ctx.Repo, name, partialErr)
+			continue
+		}
+
+		log.Debug("[%s/%s] rule [%s] matched", ctx.Owner, ctx.Repo, name)
+		for j, action := range rule.Then {
+			if partialErr := p.runAction(ctx, action, data); partialErr != nil {
+				partialErr = fmt.Errorf("rule [%s] action [%d]: %v", name, j, partialErr)
+				log.Warn("[%s/%s] %v", ctx.Owner, ctx.Repo, partialErr)
+				err = errutil.Append(err, partialErr)
+				break
+			}
+		}
+	}
+	return
+}
+
+func (p *RulesPlugin) buildTemplateData(ctx *event.EventContext) *TemplateData {
+	data := &TemplateData{
+		Event:  ctx.Type,
+		Owner:  ctx.Owner,
+		Repo:   ctx.Repo,
+		Labels: make([]string, 0),
+	}
+	data.Action, _ = ctx.Object.Action()
+	data.Number, _ = ctx.Object.Number()
+	data.Title, _ = ctx.Object.Title()
+	data.URL, _ = ctx.Object.IssueHTMLURL()
+	data.Author, _ = ctx.Object.Author()
+	data.Sender, _ = ctx.Object.SenderUser()
+	data.Body, _ = ctx.Object.Body()
+	if labels, ok := ctx.Object.Labels(); ok {
+		data.Labels = labels
+	}
+
+	switch v := ctx.Object.Payload().(type) {
+	case *github.IssuesEvent:
+		data.Label = v.GetLabel().GetName()
+	case *github.PullRequestEvent:
+		data.Label = v.GetLabel().GetName()
+	}
+	return data
+}
+
+func (p *RulesPlugin) match(m Matcher, data *TemplateData) bool {
+	matched := false
+	for _, v := range m.Events {
+		if v == data.Event || v == data.Event+"/"+data.Action {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	if len(m.Labels) == 0 {
+		return true
+	}
+	if data.Label == "" {
+		return false
+	}
+	for _, label := range m.Labels {
+		if p.ParseLabelAlias(label) == data.Label {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RulesPlugin) runAction(ctx *event.EventContext, action Action, data *TemplateData) error {
+	switch {
+	case len(action.AddLabels) > 0:
+		return p.cli.DoOperation(ctx.Ctx, &client.AddLabelOperation{
+			Owner:  ctx.Owner,
+			Repo:   ctx.Repo,
+			Number: data.Number,
+			Labels: p.parseLabels(action.AddLabels),
+		})
+	case action.ReplaceLabel != nil:
+		return p.cli.DoOperation(ctx.Ctx, &client.ReplaceLabelOperation{
+			Owner:              ctx.Owner,
+			Repo:               ctx.Repo,
+			Number:             data.Number,
+			ReplaceLabelPrefix: action.ReplaceLabel.Prefix,
+			Labels:             p.parseLabels(action.ReplaceLabel.Labels),
+		})
+	case action.RequestReviews != nil:
+		if !isPullRequest(ctx) {
+			log.Debug("[%s/%s] [%d] is not a pull request, request_reviews is skipped", ctx.Owner, ctx.Repo, data.Number)
+			return nil
+		}
+		reviewers, teams := p.reviewers(ctx, action.RequestReviews, data.Author)
+		if len(reviewers) == 0 && len(teams) == 0 {
+			return nil
+		}
+		return p.cli.DoOperation(ctx.Ctx, &client.RequestReviewsOperation{
+			Owner:         ctx.Owner,
+			Repo:          ctx.Repo,
+			Number:        data.Number,
+			Reviewers:     reviewers,
+			TeamReviewers: teams,
+		})
+	case action.Comment != "":
+		content, err := p.render(action.Comment, data)
+		if err != nil || content == "" {
+			return err
+		}
+		return p.cli.DoOperation(ctx.Ctx, &client.AddIssueCommentOperation{
+			Owner:   ctx.Owner,
+			Repo:    ctx.Repo,
+			Number:  data.Number,
+			Content: content,
+		})
+	case action.Notify != nil:
+		content, err := p.render(action.Notify.Content, data)
+		if err != nil || content == "" {
+			return err
+		}
+		return p.notifier.Send(ctx.Ctx, &action.Notify.NotifyOptions, content)
+	}
+	return nil
+}
+
+func (p *RulesPlugin) parseLabels(labels []string) []string {
+	out := make([]string, 0, len(labels))
+	for _, label := range labels {
+		out = append(out, p.ParseLabelAlias(label))
+	}
+	return out
+}
+
+// reviewers returns users and members of roles, the author can't be a reviewer of their own pull request.
+// Teams of roles are returned as slugs, only teams in the org owning the repo can be requested.
+func (p *RulesPlugin) reviewers(ctx *event.EventContext, action *RequestReviewsAction, author string) (users []string, teams []string) {
+	all := make([]string, 0)
+	for _, user := range action.Users {
+		all = append(all, p.ParseUserAlias(user))
+	}
+	teams = make([]string, 0)
+	for _, role := range action.Roles {
+		for _, entry := range p.GetRoles()[role] {
+			if org, slug, ok := config.ParseTeamRole(entry); ok {
+				if !strings.EqualFold(org, ctx.Owner) {
+					log.Warn("[%s/%s] team [%s/%s] of role [%s] is not in the org of the repo, it can't be requested",
+						ctx.Owner, ctx.Repo, org, slug, role)
+					continue
+				}
+				if !stringContains(teams, slug) {
+					teams = append(teams, slug)
+				}
+				continue
+			}
+			all = append(all, entry)
+		}
+	}
+
+	users = make([]string, 0, len(all))
+	for _, user := range all {
+		if user != author && !stringContains(users, user) {
+			users = append(users, user)
+		}
+	}
+	return users, teams
+}
+
+// render executes the template, role returns users and teams (as org/slug) of the role.
+func (p *RulesPlugin) render(text string, data *TemplateData) (string, error) {
+	tmpl, err := newTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	tmpl = tmpl.Funcs(template.FuncMap{
+		"role": func(name string) []string {
+			out := make([]string, 0)
+			for _, entry := range p.GetRoles()[name] {
+				if org, slug, ok := config.ParseTeamRole(entry); ok {
+					entry = org + "/" + slug
+				}
+				out = append(out, entry)
+			}
+			return out
+		},
+	})
+
+	buf := bytes.NewBuffer(nil)
+	if err = tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func isPullRequest(ctx *event.EventContext) bool {
+	switch v := ctx.Object.Payload().(type) {
+	case *github.IssueCommentEvent:
+		return v.GetIssue().IsPullRequest()
+	case *github.IssuesEvent:
+		return false
+	}
+	return true
+}
+
+func stringContains(strs []string, s string) bool {
+	for _, v := range strs {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,9 @@
 package status
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/fatedier/freebot/pkg/client"
 	"github.com/fatedier/freebot/pkg/config"
 	"github.com/fatedier/freebot/pkg/event"
@@ -22,11 +25,19 @@ var (
 		"pull_request_review/submitted/approved":          struct{}{},
 		"pull_request_review/submitted/commented":         struct{}{},
 		"pull_request_review/submitted/changes_requested": struct{}{},
+
+		"issues/opened":     struct{}{},
+		"issues/reopened":   struct{}{},
+		"issues/labeled":    struct{}{},
+		"issues/unlabeled":  struct{}{},
+		"issues/assigned":   struct{}{},
+		"issues/unassigned": struct{}{},
 	}
 )
 
 func init() {
-	plugin.Register(PluginName, NewStatusPlugin)
+	plugin.RegisterWithPriority(PluginName, NewStatusPlugin, 100)
+	plugin.RegisterExtra(PluginName, Extra{})
 }
 
 type LabelStatus struct {
@@ -51,6 +62,30 @@ func (ex *Extra) Complete() {
 	}
 }
 
+func (ex *Extra) Validate(options plugin.PluginOptions) []error {
+	errs := make([]error, 0)
+	for _, trigger := range sortedKeys(ex.EventsTrigger) {
+		if _, ok := SupportTriggers[trigger]; !ok {
+			errs = append(errs, fmt.Errorf("events_trigger[%q]: trigger is not supported", trigger))
+		}
+		for i, labelStatus := range ex.EventsTrigger[trigger] {
+			if labelStatus.Status == "" {
+				errs = append(errs, fmt.Errorf("events_trigger[%q][%d]: status is empty", trigger, i))
+			}
+		}
+	}
+	return errs
+}
+
+func sortedKeys(m map[string][]LabelStatus) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 type StatusPlugin struct {
 	*plugin.BasePlugin
 
@@ -73,6 +108,14 @@ func NewStatusPlugin(cli client.ClientInterface, notifier notify.NotifyInterface
 				event.ObjectNeedLabels},
 			Handler: p.handlePullRequestEvent,
 		},
+		plugin.HandlerOptions{
+			Events: []string{event.EvIssues},
+			Actions: []string{event.ActionOpened, event.ActionReopened, event.ActionLabeled, event.ActionUnlabeled,
+				event.ActionAssigned, event.ActionUnassigned},
+			ObjectNeedParams: []int{event.ObjectNeedNumber, event.ObjectNeedAction, event.ObjectNeedSenderUser,
+				event.ObjectNeedLabels},
+			Handler: p.handleIssuesEvent,
+		},
 		plugin.HandlerOptions{
 			Events:  []string{event.EvPullRequestReview},
 			Actions: []string{event.ActionSubmitted},
@@ -85,6 +128,7 @@ func NewStatusPlugin(cli client.ClientInterface, notifier notify.NotifyInterface
 			Actions:          []string{event.ActionCreated},
 			ObjectNeedParams: []int{event.ObjectNeedBody, event.ObjectNeedNumber},
 			Handler:          p.handleCommentEvent,
+			Cmds:             []string{CmdStatus},
 		},
 	}
 	options.Handlers = handlerOptions
@@ -123,7 +167,7 @@ func (p *StatusPlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 				err = p.CheckPreconditions(ctx, preconditions)
 				if err != nil {
 					log.Warn("all preconditions check failed: %v", err)
-					return
+					return &plugin.PreconditionError{Err: err}
 				}
 
 				err = p.cli.DoOperation(ctx.Ctx, &client.ReplaceLabelOperation{
@@ -150,6 +194,12 @@ func (p *StatusPlugin) handlePullRequestEvent(ctx *event.EventContext) (err erro
 	return p.handleTrigger(ctx, triggerName)
 }
 
+func (p *StatusPlugin) handleIssuesEvent(ctx *event.EventContext) (err error) {
+	action, _ := ctx.Object.Action()
+	triggerName := ctx.Type + "/" + action
+	return p.handleTrigger(ctx, triggerName)
+}
+
 func (p *StatusPlugin) handlePullRequestReviewEvent(ctx *event.EventContext) (err error) {
 	action, _ := ctx.Object.Action()
 	state, _ := ctx.Object.ReviewState()
@@ -194,3 +244,23 @@ func (p *StatusPlugin) handleTrigger(ctx *event.EventContext, triggerName string
 	}
 	return nil
 }
+
+// Commands returns one command for each status, they have different preconditions.
+func (p *StatusPlugin) Commands() []plugin.CommandInfo {
+	statuses := make([]string, 0, len(p.extra.LabelPreconditions))
+	for status := range p.extra.LabelPreconditions {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	cmds := make([]plugin.CommandInfo, 0, len(statuses))
+	for _, status := range statuses {
+		cmds = append(cmds, plugin.CommandInfo{
+			Name:          CmdStatus,
+			Args:          status,
+			Description:   fmt.Sprintf("replace %s/* labels with %s/%s", CmdStatus, CmdStatus, status),
+			Preconditions: p.extra.LabelPreconditions[status],
+		})
+	}
+	return cmds
+}
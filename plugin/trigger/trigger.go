@@ -3,8 +3,11 @@ package trigger
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os/exec"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/fatedier/freebot/pkg/client"
@@ -19,7 +22,8 @@ var (
 )
 
 func init() {
-	plugin.Register(PluginName, NewTriggerPlugin)
+	plugin.RegisterWithPriority(PluginName, NewTriggerPlugin, 40)
+	plugin.RegisterExtra(PluginName, Extra{})
 }
 
 type Executor struct {
@@ -30,24 +34,68 @@ type Executor struct {
 
 type Extra struct {
 	Cmds map[string]Executor `json:"cmds"`
+
+	// key is {event}/{action}, e.g. issues/opened
+	Events map[string]Executor `json:"events"`
 }
 
 func (ex *Extra) Complete() {
 	if ex.Cmds == nil {
 		ex.Cmds = make(map[string]Executor)
 	}
-	for _, cmd := range ex.Cmds {
+	for name, cmd := range ex.Cmds {
 		if cmd.TimeoutS <= 0 {
 			cmd.TimeoutS = 30
+			ex.Cmds[name] = cmd
+		}
+	}
+
+	if ex.Events == nil {
+		ex.Events = make(map[string]Executor)
+	}
+	for name, executor := range ex.Events {
+		if executor.TimeoutS <= 0 {
+			executor.TimeoutS = 30
+			ex.Events[name] = executor
 		}
 	}
 }
 
+func (ex *Extra) Validate(options plugin.PluginOptions) []error {
+	errs := make([]error, 0)
+	for _, name := range sortedKeys(ex.Cmds) {
+		if ex.Cmds[name].Command == "" {
+			errs = append(errs, fmt.Errorf("cmds[%q]: command is empty", name))
+		}
+	}
+	for _, name := range sortedKeys(ex.Events) {
+		executor := ex.Events[name]
+		if arrs := strings.Split(name, "/"); len(arrs) != 2 || arrs[0] == "" || arrs[1] == "" {
+			errs = append(errs, fmt.Errorf("events[%q]: key should be {event}/{action}", name))
+		}
+		if executor.Command == "" {
+			errs = append(errs, fmt.Errorf("events[%q]: command is empty", name))
+		}
+	}
+	return errs
+}
+
+func sortedKeys(m map[string]Executor) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 type EventInfo struct {
 	EventType string   `json:"event_type"`
+	Action    string   `json:"action,omitempty"`
 	Owner     string   `json:"owner"`
 	Repo      string   `json:"repo"`
 	Number    int      `json:"number"`
+	Sender    string   `json:"sender,omitempty"`
 	Labels    []string `json:"labels"`
 }
 
@@ -71,6 +119,12 @@ func NewTriggerPlugin(cli client.ClientInterface, notifier notify.NotifyInterfac
 			Actions:          []string{event.ActionCreated},
 			ObjectNeedParams: []int{event.ObjectNeedBody, event.ObjectNeedNumber},
 			Handler:          p.handleCommentEvent,
+			IsCmd:            p.isTriggerCmd,
+		},
+		plugin.HandlerOptions{
+			Events:           []string{event.EvIssues},
+			ObjectNeedParams: []int{event.ObjectNeedNumber, event.ObjectNeedAction},
+			Handler:          p.handleIssuesEvent,
 		},
 	}
 	options.Handlers = handlerOptions
@@ -85,6 +139,11 @@ func NewTriggerPlugin(cli client.ClientInterface, notifier notify.NotifyInterfac
 	return p, nil
 }
 
+func (p *TriggerPlugin) isTriggerCmd(name string) bool {
+	executor, ok := p.extra.Cmds[name]
+	return ok && executor.Command != ""
+}
+
 func (p *TriggerPlugin) handleCommentEvent(ctx *event.EventContext) (err error) {
 	msg, _ := ctx.Object.Body()
 	number, _ := ctx.Object.Number()
@@ -113,41 +172,94 @@ func (p *TriggerPlugin) handleCommentEvent(ctx *event.EventContext) (err error)
 			info.Labels = labels
 		}
 
-		buf, _ := json.Marshal(info)
-
-		newCtx, cancel := context.WithDeadline(ctx.Ctx, time.Now().Add(time.Duration(executor.TimeoutS)*time.Second))
-		defer cancel()
-
-		args := append(executor.Args, cmd.Args...)
-		process := exec.CommandContext(newCtx, executor.Command, args...)
-		stdin, err := process.StdinPipe()
+		err = p.execute(ctx, executor, cmd.Args, info)
 		if err != nil {
-			log.Warn("exec [%s] error: %v", executor.Command, err)
 			return err
 		}
+	}
+	return
+}
+
+func (p *TriggerPlugin) handleIssuesEvent(ctx *event.EventContext) (err error) {
+	action, _ := ctx.Object.Action()
+	executor, ok := p.extra.Events[ctx.Type+"/"+action]
+	if !ok || executor.Command == "" {
+		return nil
+	}
+
+	number, _ := ctx.Object.Number()
+	info := &EventInfo{
+		EventType: ctx.Type,
+		Action:    action,
+		Owner:     ctx.Owner,
+		Repo:      ctx.Repo,
+		Number:    number,
+		Labels:    make([]string, 0),
+	}
+	if labels, ok := ctx.Object.Labels(); ok {
+		info.Labels = labels
+	}
+	info.Sender, _ = ctx.Object.SenderUser()
+
+	return p.execute(ctx, executor, nil, info)
+}
+
+// execute runs executor with info as stdin, output is sent as a comment if not empty.
+// Scripts are not run in dry-run mode because they may have side effects.
+func (p *TriggerPlugin) execute(ctx *event.EventContext, executor Executor, extraArgs []string, info *EventInfo) error {
+	if p.IsDryRun() {
+		log.Info("[%s/%s] dry-run, exec [%s] is skipped", ctx.Owner, ctx.Repo, executor.Command)
+		return nil
+	}
+	buf, _ := json.Marshal(info)
 
-		go func() {
-			defer stdin.Close()
-			io.WriteString(stdin, string(buf)+"\n")
-		}()
+	newCtx, cancel := context.WithDeadline(ctx.Ctx, time.Now().Add(time.Duration(executor.TimeoutS)*time.Second))
+	defer cancel()
 
-		out, err := process.CombinedOutput()
+	args := append(append([]string{}, executor.Args...), extraArgs...)
+	process := exec.CommandContext(newCtx, executor.Command, args...)
+	stdin, err := process.StdinPipe()
+	if err != nil {
+		log.Warn("exec [%s] error: %v", executor.Command, err)
+		return err
+	}
+
+	go func() {
+		defer stdin.Close()
+		io.WriteString(stdin, string(buf)+"\n")
+	}()
+
+	out, err := process.CombinedOutput()
+	if err != nil {
+		log.Warn("exec [%s] error: %v", executor.Command, err)
+		return err
+	}
+
+	if len(out) > 0 {
+		err = p.cli.DoOperation(ctx.Ctx, &client.AddIssueCommentOperation{
+			Owner:   ctx.Owner,
+			Repo:    ctx.Repo,
+			Number:  info.Number,
+			Content: string(out),
+		})
 		if err != nil {
-			log.Warn("exec [%s] error: %v", executor.Command, err)
 			return err
 		}
+	}
+	return nil
+}
 
-		if len(out) > 0 {
-			err = p.cli.DoOperation(ctx.Ctx, &client.AddIssueCommentOperation{
-				Owner:   ctx.Owner,
-				Repo:    ctx.Repo,
-				Number:  number,
-				Content: string(out),
-			})
-			if err != nil {
-				return err
-			}
+func (p *TriggerPlugin) Commands() []plugin.CommandInfo {
+	cmds := make([]plugin.CommandInfo, 0, len(p.extra.Cmds))
+	for _, name := range sortedKeys(p.extra.Cmds) {
+		if !p.isTriggerCmd(name) {
+			continue
 		}
+		cmds = append(cmds, plugin.CommandInfo{
+			Name:        name,
+			Args:        "[args]",
+			Description: "run the script configured, its output is replied as a comment",
+		})
 	}
-	return
+	return cmds
 }
@@ -0,0 +1,211 @@
+package freebot
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/fatedier/freebot/pkg/log"
+)
+
+// reloadableFields are json names of Config fields applied by ReloadConfig, changes of other fields require restarting.
+var reloadableFields = map[string]struct{}{
+	"log_level":                       {},
+	"github_access_token":             {},
+	"github_app_private_key":          {},
+	"github_app_id":                   {},
+	"admin_token":                     {},
+	"webhook_secret":                  {},
+	"webhook_allow_sha1":              {},
+	"ignore_senders":                  {},
+	"repo_confs":                      {},
+	"templates":                       {},
+	"org_defaults":                    {},
+	"in_repo_conf":                    {},
+	"in_repo_conf_precedence":         {},
+	"in_repo_conf_plugins":            {},
+	"in_repo_conf_unconfigured_repos": {},
+	"team_cache_ttl_s":                {},
+}
+
+// ReloadConfig reads the config file again and applies it without dropping in flight deliveries.
+// The new github client, repo confs and plugins are prepared first and swapped in together,
+// nothing is changed if the config or the github client fails. Repos failing to load keep
+// their last good confs and plugins.
+func (svc *Service) ReloadConfig() error {
+	if svc.configLoader == nil {
+		return fmt.Errorf("config file is unknown")
+	}
+	cfg, err := svc.configLoader()
+	if err != nil {
+		return err
+	}
+	cfg.Complete()
+	if err = cfg.Check(); err != nil {
+		return err
+	}
+
+	svc.reloadMu.Lock()
+	defer svc.reloadMu.Unlock()
+
+	svc.mu.RLock()
+	old := svc.Config
+	auth := svc.auth
+	svc.mu.RUnlock()
+
+	if fields := restartRequiredChanges(old, cfg); len(fields) > 0 {
+		log.Warn("changes of %s require restarting freebot, they are ignored", strings.Join(fields, ", "))
+	}
+
+	if cfg.GithubAccessToken != old.GithubAccessToken || cfg.GithubAppPrivateKey != old.GithubAppPrivateKey ||
+		cfg.GithubAppID != old.GithubAppID {
+		auth, err = newGithubAuth(cfg)
+		if err != nil {
+			return fmt.Errorf("create github client error: %v", err)
+		}
+		log.Info("github credentials changed, authenticated as [%s]", auth.botLogin)
+	}
+	env := newPluginsEnv(cfg, auth)
+
+	files, fileErrs := svc.repoConfFiles, svc.repoConfFileErrs
+	if svc.RepoConfDir != "" {
+		files, fileErrs, err = svc.loadRepoConfsFromDir(svc.RepoConfDir, svc.repoConfFiles)
+		if err != nil {
+			return fmt.Errorf("load repo confs from dir error: %v", err)
+		}
+	}
+	svc.mu.RLock()
+	oldRepoConfs := svc.repoConfs
+	svc.mu.RUnlock()
+	all, confErrs, err := svc.buildRepoConfs(env.resolver, cfg.RepoConfs, files, oldRepoConfs)
+	if err != nil {
+		return err
+	}
+	plugins, pluginErrs := svc.createPlugins(env, all)
+
+	svc.mu.Lock()
+	svc.LogLevel = cfg.LogLevel
+	svc.GithubAccessToken = cfg.GithubAccessToken
+	svc.GithubAppPrivateKey = cfg.GithubAppPrivateKey
+	svc.GithubAppID = cfg.GithubAppID
+	svc.AdminToken = cfg.AdminToken
+	svc.WebhookSecret = cfg.WebhookSecret
+	svc.WebhookAllowSHA1 = cfg.WebhookAllowSHA1
+	svc.IgnoreSenders = cfg.IgnoreSenders
+	svc.RepoConfs = cfg.RepoConfs
+	svc.Templates = cfg.Templates
+	svc.OrgDefaults = cfg.OrgDefaults
+	svc.InRepoConf = cfg.InRepoConf
+	svc.InRepoConfPrecedence = cfg.InRepoConfPrecedence
+	svc.InRepoConfPlugins = cfg.InRepoConfPlugins
+	svc.InRepoConfUnconfiguredRepos = cfg.InRepoConfUnconfiguredRepos
+	svc.TeamCacheTTLS = cfg.TeamCacheTTLS
+	svc.auth = auth
+	svc.env = env
+	svc.repoConfs = all
+	svc.mu.Unlock()
+
+	// deliveries in flight keep using plugins and the client they got
+	svc.eventHandler.SetRequireInstallation(auth.appTransport != nil)
+	svc.eventHandler.SetIgnoreSenders(append([]string{auth.botLogin}, cfg.IgnoreSenders...), svc.ignoreBotSenders)
+	svc.eventHandler.UpdatePlugins(plugins, svc.newPluginsCreator(env, all))
+	svc.confErrors.Reset(mergeRepoConfErrors(fileErrs, confErrs, pluginErrs))
+	logRepoConfsDiff(oldRepoConfs, all)
+	svc.staticRepoConfs = cfg.RepoConfs
+	svc.repoConfFiles = files
+	svc.repoConfFileErrs = fileErrs
+	log.SetLogLevel(cfg.LogLevel)
+	log.Info("reload config success")
+	return nil
+}
+
+// restartRequiredChanges returns json names of changed fields which can't be reloaded.
+func restartRequiredChanges(old Config, new Config) []string {
+	fields := make([]string, 0)
+	oldValue, newValue := reflect.ValueOf(old), reflect.ValueOf(new)
+	for i := 0; i < oldValue.NumField(); i++ {
+		name := strings.Split(oldValue.Type().Field(i).Tag.Get("json"), ",")[0]
+		if _, ok := reloadableFields[name]; ok {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// logRepoConfsDiff logs repos added, removed and paths of values changed in their confs.
+// Values are not logged since they may contain secrets.
+func logRepoConfsDiff(old map[string]RepoConf, new map[string]RepoConf) {
+	keys := make([]string, 0, len(old)+len(new))
+	for k := range old {
+		keys = append(keys, k)
+	}
+	for k := range new {
+		if _, ok := old[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		oldConf, inOld := old[k]
+		newConf, inNew := new[k]
+		switch {
+		case !inOld:
+			log.Info("repo conf [%s] added", k)
+		case !inNew:
+			log.Info("repo conf [%s] removed", k)
+		default:
+			if paths := diffPaths("", toGeneric(oldConf), toGeneric(newConf)); len(paths) > 0 {
+				log.Info("repo conf [%s] changed: %s", k, strings.Join(paths, ", "))
+			}
+		}
+	}
+}
+
+func toGeneric(v interface{}) interface{} {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	json.Unmarshal(buf, &out)
+	return out
+}
+
+// diffPaths returns paths of values different in a and b, objects are compared by fields.
+func diffPaths(prefix string, a interface{}, b interface{}) []string {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if reflect.DeepEqual(a, b) {
+			return nil
+		}
+		return []string{prefix}
+	}
+
+	keys := make([]string, 0, len(am)+len(bm))
+	for k := range am {
+		keys = append(keys, k)
+	}
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	paths := make([]string, 0)
+	for _, k := range keys {
+		p := k
+		if prefix != "" {
+			p = prefix + "." + k
+		}
+		paths = append(paths, diffPaths(p, am[k], bm[k])...)
+	}
+	return paths
+}
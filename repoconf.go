@@ -0,0 +1,220 @@
+package freebot
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// isRepoPattern returns true if the key of repo conf is a glob pattern, e.g. owner/*
+func isRepoPattern(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// matchRepoConf finds the conf of repo fullName(owner/repo) and the key it resolved to.
+// Exact key wins over patterns, the most specific one wins if more than one pattern matches.
+func matchRepoConf(repoConfs map[string]RepoConf, fullName string) (conf RepoConf, key string, ok bool) {
+	if conf, ok = repoConfs[fullName]; ok {
+		return conf, fullName, true
+	}
+
+	for pattern, c := range repoConfs {
+		if !isRepoPattern(pattern) {
+			continue
+		}
+		if matched, _ := path.Match(pattern, fullName); !matched {
+			continue
+		}
+
+		if !ok || morePatternSpecific(pattern, key) {
+			conf, key, ok = c, pattern, true
+		}
+	}
+	return
+}
+
+// morePatternSpecific compares patterns by the length of non-wildcard characters,
+// the result is stable for patterns with same length.
+func morePatternSpecific(a, b string) bool {
+	la := len(a) - strings.Count(a, "*") - strings.Count(a, "?")
+	lb := len(b) - strings.Count(b, "*") - strings.Count(b, "?")
+	if la != lb {
+		return la > lb
+	}
+	return a < b
+}
+
+// UnmarshalJSON decodes the conf and keeps the raw json object, which is needed by deep merging
+// to tell fields not set from fields set to zero values or null.
+func (conf *RepoConf) UnmarshalJSON(b []byte) error {
+	type plain RepoConf
+	v := plain{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	raw := make(map[string]interface{})
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*conf = RepoConf(v)
+	conf.raw = raw
+	return nil
+}
+
+// rawObject returns a copy of the raw json object of conf, null values are kept so they can
+// still remove entries when conf is merged on its templates later.
+// Confs not decoded from json are converted with null fields dropped.
+func (conf RepoConf) rawObject() map[string]interface{} {
+	if conf.raw != nil {
+		return deepMerge(nil, conf.raw).(map[string]interface{})
+	}
+	buf, _ := json.Marshal(conf)
+	out := make(map[string]interface{})
+	json.Unmarshal(buf, &out)
+	return stripNulls(out).(map[string]interface{})
+}
+
+// repoConfFromRaw decodes raw without null values, raw is kept in the result for further merging.
+func repoConfFromRaw(raw map[string]interface{}) (conf RepoConf, err error) {
+	buf, err := json.Marshal(stripNulls(raw))
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(buf, &conf); err != nil {
+		return
+	}
+	conf.raw = raw
+	return
+}
+
+// deepMerge merges src into dst and returns the result, dst and src are not modified.
+// Objects are merged recursively, other values in src replace values in dst.
+// Null values in src are kept in the result and removed by stripNulls after all merging is done,
+// so that null removes the entry inherited from any conf merged before.
+func deepMerge(dst interface{}, src interface{}) interface{} {
+	srcObj, ok := src.(map[string]interface{})
+	if !ok {
+		return src
+	}
+
+	out := make(map[string]interface{})
+	if dstObj, ok := dst.(map[string]interface{}); ok {
+		for k, v := range dstObj {
+			out[k] = v
+		}
+	}
+	for k, v := range srcObj {
+		out[k] = deepMerge(out[k], v)
+	}
+	return out
+}
+
+// stripNulls returns a copy of v with null values in objects removed.
+func stripNulls(v interface{}) interface{} {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	out := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		if v != nil {
+			out[k] = stripNulls(v)
+		}
+	}
+	return out
+}
+
+// mergeRepoConf deep merges src into dst, see deepMerge.
+func mergeRepoConf(dst RepoConf, src RepoConf) (RepoConf, error) {
+	return repoConfFromRaw(deepMerge(dst.rawObject(), src.rawObject()).(map[string]interface{}))
+}
+
+// repoConfResolver resolves templates in "extends" and org defaults of repo confs.
+type repoConfResolver struct {
+	templates   map[string]RepoConf
+	orgDefaults map[string]RepoConf
+}
+
+func newRepoConfResolver(templates map[string]RepoConf, orgDefaults map[string]RepoConf) *repoConfResolver {
+	return &repoConfResolver{
+		templates:   templates,
+		orgDefaults: orgDefaults,
+	}
+}
+
+// Check resolves all templates and org defaults, the first error found is returned.
+func (r *repoConfResolver) Check() error {
+	for _, name := range sortedRepoKeys(r.templates) {
+		if _, err := r.resolveRaw(RepoConf{Extends: []string{name}}, nil); err != nil {
+			return fmt.Errorf("templates[%q]: %v", name, err)
+		}
+	}
+	for _, owner := range sortedRepoKeys(r.orgDefaults) {
+		if _, err := r.resolveRaw(r.orgDefaults[owner], nil); err != nil {
+			return fmt.Errorf("org_defaults[%q]: %v", owner, err)
+		}
+	}
+	return nil
+}
+
+// Resolve returns the conf of key(owner/repo or a pattern) merged on its templates and the defaults of its owner.
+func (r *repoConfResolver) Resolve(key string, conf RepoConf) (RepoConf, error) {
+	raw, err := r.resolveRaw(conf, nil)
+	if err != nil {
+		return RepoConf{}, err
+	}
+
+	owner := strings.Split(key, "/")[0]
+	if orgDefault, ok := r.orgDefaults[owner]; ok {
+		base, err := r.resolveRaw(orgDefault, nil)
+		if err != nil {
+			return RepoConf{}, fmt.Errorf("org_defaults[%q]: %v", owner, err)
+		}
+		raw = deepMerge(base, raw).(map[string]interface{})
+	}
+	return repoConfFromRaw(raw)
+}
+
+// OrgDefault returns the resolved default conf of owner.
+func (r *repoConfResolver) OrgDefault(owner string) (conf RepoConf, ok bool, err error) {
+	orgDefault, ok := r.orgDefaults[owner]
+	if !ok {
+		return RepoConf{}, false, nil
+	}
+	conf, err = r.ResolveTemplates(orgDefault)
+	return conf, true, err
+}
+
+// ResolveTemplates returns the conf merged on its templates, org defaults are not used.
+func (r *repoConfResolver) ResolveTemplates(conf RepoConf) (RepoConf, error) {
+	raw, err := r.resolveRaw(conf, nil)
+	if err != nil {
+		return RepoConf{}, err
+	}
+	return repoConfFromRaw(raw)
+}
+
+// resolveRaw merges templates in order and then conf itself, visiting is used to find circular extends.
+func (r *repoConfResolver) resolveRaw(conf RepoConf, visiting []string) (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	for _, name := range conf.Extends {
+		if stringContains(visiting, name) {
+			return nil, fmt.Errorf("circular extends: %s -> %s", strings.Join(visiting, " -> "), name)
+		}
+		tpl, ok := r.templates[name]
+		if !ok {
+			return nil, fmt.Errorf("template [%s] not found", name)
+		}
+		tplRaw, err := r.resolveRaw(tpl, append(append([]string{}, visiting...), name))
+		if err != nil {
+			return nil, err
+		}
+		out = deepMerge(out, tplRaw).(map[string]interface{})
+	}
+
+	raw := conf.rawObject()
+	// templates are resolved, the result should not be resolved again
+	delete(raw, "extends")
+	return deepMerge(out, raw).(map[string]interface{}), nil
+}
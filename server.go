@@ -2,29 +2,48 @@ package freebot
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fatedier/freebot/pkg/client"
 	"github.com/fatedier/freebot/pkg/client/githubapp"
 	"github.com/fatedier/freebot/pkg/config"
+	"github.com/fatedier/freebot/pkg/deadletter"
+	"github.com/fatedier/freebot/pkg/dedup"
+	"github.com/fatedier/freebot/pkg/event"
 	"github.com/fatedier/freebot/pkg/httputil"
 	"github.com/fatedier/freebot/pkg/log"
+	"github.com/fatedier/freebot/pkg/metrics"
 	"github.com/fatedier/freebot/pkg/notify"
+	"github.com/fatedier/freebot/pkg/queue"
+	"github.com/fatedier/freebot/pkg/team"
+	"github.com/fatedier/freebot/pkg/watcher"
+	"github.com/fatedier/freebot/pkg/webhook"
 	"github.com/fatedier/freebot/plugin"
 	_ "github.com/fatedier/freebot/plugin/assign"
+	_ "github.com/fatedier/freebot/plugin/help"
 	_ "github.com/fatedier/freebot/plugin/label"
 	_ "github.com/fatedier/freebot/plugin/lgtm"
 	_ "github.com/fatedier/freebot/plugin/lifecycle"
 	_ "github.com/fatedier/freebot/plugin/merge"
 	_ "github.com/fatedier/freebot/plugin/module"
 	_ "github.com/fatedier/freebot/plugin/notify"
+	_ "github.com/fatedier/freebot/plugin/remote"
+	_ "github.com/fatedier/freebot/plugin/rules"
 	_ "github.com/fatedier/freebot/plugin/status"
 	_ "github.com/fatedier/freebot/plugin/trigger"
 
@@ -32,8 +51,18 @@ import (
 	"golang.org/x/oauth2"
 )
 
+const (
+	// repo label of metrics for repos matching no conf
+	metricsUnknownRepo = "other"
+
+	// a delivery with this header set to admin_token is processed even if its id has been seen
+	HeaderForceReprocess = "X-Freebot-Force-Reprocess"
+)
+
 type Config struct {
 	BindAddr            string `json:"bind_addr"`
+	TLSCertFile         string `json:"tls_cert_file"`
+	TLSKeyFile          string `json:"tls_key_file"`
 	LogLevel            string `json:"log_level"`
 	LogFile             string `json:"log_file"`
 	LogMaxDays          int64  `json:"log_max_days"`
@@ -41,103 +70,488 @@ type Config struct {
 	GithubAppPrivateKey string `json:"github_app_private_key"`
 	GithubAppID         int    `json:"github_app_id"`
 
+	// http server timeouts, 0 means default value, negative means no timeout
+	ReadHeaderTimeoutS int `json:"read_header_timeout_s"`
+	ReadTimeoutS       int `json:"read_timeout_s"`
+	WriteTimeoutS      int `json:"write_timeout_s"`
+	IdleTimeoutS       int `json:"idle_timeout_s"`
+	// max time waiting for in flight deliveries when shutting down
+	ShutdownTimeoutS int `json:"shutdown_timeout_s"`
+
+	// admin api is served on admin_bind_addr if set, token is required by operator actions
+	AdminBindAddr string `json:"admin_bind_addr"`
+	AdminToken    string `json:"admin_token"`
+
+	// secret used to verify the signature of webhook deliveries, can be overridden by repo conf
+	WebhookSecret string `json:"webhook_secret"`
+	// accept the legacy X-Hub-Signature(SHA-1) header if X-Hub-Signature-256 is absent
+	WebhookAllowSHA1 bool `json:"webhook_allow_sha1"`
+
+	// events sent by these users are ignored, the authenticated user or app is always ignored
+	IgnoreSenders []string `json:"ignore_senders"`
+
 	// repo -> plugin
 	RepoConfs map[string]RepoConf `json:"repo_confs"`
+	// named repo confs inherited by repo confs listing them in "extends"
+	Templates map[string]RepoConf `json:"templates"`
+	// owner -> repo conf inherited by all confs of repos of this owner
+	OrgDefaults map[string]RepoConf `json:"org_defaults"`
 
+	// changes of files in repo_conf_dir are watched and applied after no more changes in repo_conf_dir_debounce_ms,
+	// the dir is polled every repo_conf_dir_update_interval_s if watching is not supported
 	RepoConfDir                string `json:"repo_conf_dir"`
 	RepoConfDirUpdateIntervalS int    `json:"repo_conf_dir_update_interval_s"`
+	RepoConfDirDebounceMS      int    `json:"repo_conf_dir_debounce_ms"`
+
+	// read repo conf from .github/freebot.{json,yaml,yml} in the default branch of each repo,
+	// it is merged with the host conf and reloaded when a push to the default branch changes it
+	InRepoConf bool `json:"in_repo_conf"`
+	// "host"(default) or "repo", decides which conf wins if both define the same alias, role, label or plugin
+	InRepoConfPrecedence string `json:"in_repo_conf_precedence"`
+	// plugins in-repo conf can enable or configure, all plugins except DefaultInRepoConfDeniedPlugins if not set
+	InRepoConfPlugins []string `json:"in_repo_conf_plugins"`
+	// also read in-repo conf of repos matching no repo conf or org default
+	InRepoConfUnconfiguredRepos bool `json:"in_repo_conf_unconfigured_repos"`
+
+	// members of teams referred by roles as team:{org}/{slug} are cached for team_cache_ttl_s,
+	// they are also dropped when membership or team events are received
+	TeamCacheTTLS int `json:"team_cache_ttl_s"`
+
+	// if set, deliveries are persisted in this dir and handled asynchronously by workers
+	EventQueueDir            string `json:"event_queue_dir"`
+	EventQueueWorkers        int    `json:"event_queue_workers"`
+	EventQueueMaxAttempts    int    `json:"event_queue_max_attempts"`
+	EventQueueRetryBackoffS  int    `json:"event_queue_retry_backoff_s"`
+	EventQueueHandleTimeoutS int    `json:"event_queue_handle_timeout_s"`
+
+	// deliveries with the same X-GitHub-Delivery id are only processed once in dedup_ttl_s,
+	// ids are kept in memory if dedup_file is empty
+	DedupTTLS       int    `json:"dedup_ttl_s"`
+	DedupMaxEntries int    `json:"dedup_max_entries"`
+	DedupFile       string `json:"dedup_file"`
+
+	// events failed to be handled by plugins are saved in this dir and can be replayed
+	DeadLetterDir string `json:"dead_letter_dir"`
+}
+
+// Complete sets default values of fields not set.
+func (cfg *Config) Complete() {
+	if cfg.LogMaxDays <= 0 {
+		cfg.LogMaxDays = 3
+	}
+	if cfg.RepoConfDirUpdateIntervalS <= 0 {
+		cfg.RepoConfDirUpdateIntervalS = 5
+	}
+	if cfg.RepoConfDirDebounceMS <= 0 {
+		cfg.RepoConfDirDebounceMS = 500
+	}
+	if cfg.ReadHeaderTimeoutS == 0 {
+		cfg.ReadHeaderTimeoutS = 10
+	}
+	if cfg.ReadTimeoutS == 0 {
+		cfg.ReadTimeoutS = 30
+	}
+	if cfg.WriteTimeoutS == 0 {
+		cfg.WriteTimeoutS = 120
+	}
+	if cfg.IdleTimeoutS == 0 {
+		cfg.IdleTimeoutS = 120
+	}
+	if cfg.ShutdownTimeoutS <= 0 {
+		cfg.ShutdownTimeoutS = 30
+	}
+	if cfg.DedupTTLS <= 0 {
+		cfg.DedupTTLS = 72 * 3600
+	}
+	if cfg.DedupMaxEntries <= 0 {
+		cfg.DedupMaxEntries = 10000
+	}
+	if cfg.InRepoConfPrecedence == "" {
+		cfg.InRepoConfPrecedence = InRepoConfPrecedenceHost
+	}
+	if cfg.TeamCacheTTLS <= 0 {
+		cfg.TeamCacheTTLS = 300
+	}
+}
+
+func (cfg *Config) Check() error {
+	if cfg.InRepoConfPrecedence != InRepoConfPrecedenceHost && cfg.InRepoConfPrecedence != InRepoConfPrecedenceRepo {
+		return fmt.Errorf("in_repo_conf_precedence should be %s or %s", InRepoConfPrecedenceHost, InRepoConfPrecedenceRepo)
+	}
+	return nil
 }
 
 type RepoConf struct {
+	// names of templates inherited in order, fields of this conf are deep merged on them
+	Extends    []string                `json:"extends,omitempty"`
 	Alias      config.AliasOptions     `json:"alias"`
 	Roles      config.RoleOptions      `json:"roles"`       // role -> []string{user1, user2}
 	LabelRoles config.LabelRoles       `json:"label_roles"` // label -> role -> users
 	Plugins    map[string]PluginConfig `json:"plugins"`
+
+	// override the global webhook_secret for this repo
+	WebhookSecret string `json:"webhook_secret"`
+	// ignore events sent by any bot account, not only freebot itself
+	IgnoreBotSenders bool `json:"ignore_bot_senders"`
+	// roles, label roles, preconditions and extra of plugins in host conf can't be overridden by in-repo conf
+	LockPreconditions bool `json:"lock_preconditions"`
+	// all plugins of the repo record operations instead of doing them
+	DryRun bool `json:"dry_run"`
+	// how users are told when their commands are rejected or fail
+	Feedback config.FeedbackOptions `json:"feedback"`
+
+	// raw json object, see UnmarshalJSON
+	raw map[string]interface{}
 }
 
 type PluginConfig struct {
 	Disable       bool                  `json:"disable"`
+	Priority      *int                  `json:"priority"` // default priority of the plugin is used if not set
 	Preconditions []config.Precondition `json:"preconditions"`
 	Extra         interface{}           `json:"extra"`
+	// operations are recorded instead of being done, read calls still go to github
+	DryRun bool `json:"dry_run"`
+}
+
+// GetPriority returns the priority of plugin, plugins with higher priority handle events earlier.
+func (conf PluginConfig) GetPriority(name string) int {
+	if conf.Priority != nil {
+		return *conf.Priority
+	}
+	return plugin.DefaultPriority(name)
+}
+
+// githubAuth is the github client created with credentials in config.
+type githubAuth struct {
+	cli          client.ClientInterface
+	appTransport *githubapp.GithubAppInstallTransport
+	// login of the authenticated user or the bot user of github app
+	botLogin string
+}
+
+// pluginsEnv is used to create plugins, it is replaced as a whole when config is reloaded
+// so plugins are never created with a mix of old and new config.
+type pluginsEnv struct {
+	cli                  client.ClientInterface
+	resolver             *repoConfResolver
+	inRepoConf           bool
+	inRepoConfPrecedence string
+	// nil means all plugins except DefaultInRepoConfDeniedPlugins
+	inRepoConfPlugins           []string
+	inRepoConfUnconfiguredRepos bool
+	teams                       *team.Cache
 }
 
 type Service struct {
+	// fields of Config which can be reloaded are guarded by mu, see ReloadConfig
 	Config
 
 	eventHandler *EventHandler
-	cli          client.ClientInterface
 	notifier     notify.NotifyInterface
+	queue        *queue.DiskQueue
+	dedup        dedup.Store
+	deadLetters  *deadletter.Store
 
 	staticRepoConfs map[string]RepoConf
-	extraRepoConfs  map[string]RepoConf
+	// file -> repo confs, the last good confs are kept if a file fails to load
+	repoConfFiles    map[string]map[string]RepoConf
+	repoConfFileErrs map[string]*RepoConfError
+
+	// errors of repo conf files, repo confs and plugins, failed repos keep their last good plugins
+	confErrors  *repoConfErrors
+	goodPlugins *goodPlugins
+	// shared by all repos so reloading config doesn't reset it
+	feedbackLimiter *plugin.FeedbackLimiter
+
+	// merged repo confs, key is owner/repo
+	repoConfs map[string]RepoConf
+	// confs read from repos, only used if in_repo_conf is enabled
+	inRepoConfs *inRepoConfCache
+	auth        *githubAuth
+	env         pluginsEnv
+	mu          sync.RWMutex
+
+	// serialize reloading of config and repo confs
+	reloadMu sync.Mutex
+	// returns the config in config file, used when SIGHUP is received
+	configLoader func() (Config, error)
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
 }
 
 func NewService(cfg Config) (*Service, error) {
-	if cfg.LogMaxDays <= 0 {
-		cfg.LogMaxDays = 3
-	}
+	cfg.Complete()
 	if cfg.LogFile == "" {
 		log.InitLog("console", "", cfg.LogLevel, cfg.LogMaxDays)
 	} else {
 		log.InitLog("file", cfg.LogFile, cfg.LogLevel, cfg.LogMaxDays)
 	}
-	if cfg.RepoConfDirUpdateIntervalS <= 0 {
-		cfg.RepoConfDirUpdateIntervalS = 5
+	if err := cfg.Check(); err != nil {
+		return nil, err
 	}
 
 	svc := &Service{
-		Config: cfg,
+		Config:      cfg,
+		inRepoConfs: newInRepoConfCache(),
+		confErrors:  newRepoConfErrors(),
+		goodPlugins: newGoodPlugins(),
+		stopCh:      make(chan struct{}),
+
+		feedbackLimiter: plugin.NewFeedbackLimiter(),
 	}
 
 	svc.notifier = notify.NewNotifyController()
 
-	requireInstallation := false
+	dedupTTL := time.Duration(cfg.DedupTTLS) * time.Second
+	if cfg.DedupFile != "" {
+		store, err := dedup.NewFileStore(cfg.DedupFile, dedupTTL, cfg.DedupMaxEntries)
+		if err != nil {
+			return nil, err
+		}
+		svc.dedup = store
+	} else {
+		svc.dedup = dedup.NewMemoryStore(dedupTTL, cfg.DedupMaxEntries)
+	}
+
+	auth, err := newGithubAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+	svc.auth = auth
+	svc.env = newPluginsEnv(cfg, auth)
+	if auth.botLogin != "" {
+		log.Info("authenticated as [%s], events sent by it are ignored", auth.botLogin)
+	}
+
+	// repos with invalid confs are reported and skipped, other repos still work
+	svc.staticRepoConfs = cfg.RepoConfs
+	if svc.RepoConfDir != "" {
+		svc.repoConfFiles, svc.repoConfFileErrs, err = svc.loadRepoConfsFromDir(svc.RepoConfDir, nil)
+		if err != nil {
+			return nil, fmt.Errorf("load repo confs from dir error: %v", err)
+		}
+	}
+	repoConfs, confErrs, err := svc.buildRepoConfs(svc.env.resolver, svc.staticRepoConfs, svc.repoConfFiles, nil)
+	if err != nil {
+		return nil, err
+	}
+	plugins, pluginErrs := svc.createPlugins(svc.env, repoConfs)
+	svc.confErrors.Reset(mergeRepoConfErrors(svc.repoConfFileErrs, confErrs, pluginErrs))
+	svc.repoConfs = repoConfs
+
+	svc.eventHandler = NewEventHandler(auth.appTransport != nil, plugins, svc.newPluginsCreator(svc.env, repoConfs))
+	svc.eventHandler.SetIgnoreSenders(append([]string{auth.botLogin}, cfg.IgnoreSenders...), svc.ignoreBotSenders)
+
+	if cfg.DeadLetterDir != "" {
+		svc.deadLetters, err = deadletter.NewStore(cfg.DeadLetterDir)
+		if err != nil {
+			return nil, fmt.Errorf("create dead letter store error: %v", err)
+		}
+	}
+
+	if cfg.EventQueueDir != "" {
+		svc.queue, err = queue.NewDiskQueue(queue.Options{
+			Dir:           cfg.EventQueueDir,
+			Workers:       cfg.EventQueueWorkers,
+			MaxAttempts:   cfg.EventQueueMaxAttempts,
+			BackoffBase:   time.Duration(cfg.EventQueueRetryBackoffS) * time.Second,
+			HandleTimeout: time.Duration(cfg.EventQueueHandleTimeoutS) * time.Second,
+			OnDrop: func(d *queue.Delivery, err error) {
+				log.Error("event [%s], id [%s] dropped after %d attempts: %v", d.EventType, d.ID, d.Attempts, err)
+				svc.saveFailedEvent(d.ID, d.EventType, d.Payload, err)
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create event queue error: %v", err)
+		}
+		log.Info("event queue [%s] loaded, %d deliveries pending", cfg.EventQueueDir, svc.queue.Len())
+	}
+	return svc, nil
+}
+
+// newGithubAuth creates the github client with the access token or github app in cfg.
+func newGithubAuth(cfg Config) (*githubAuth, error) {
+	auth := &githubAuth{}
 	if cfg.GithubAccessToken != "" {
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: cfg.GithubAccessToken},
 		)
-		tc := oauth2.NewClient(context.Background(), ts)
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+			Transport: client.NewMetricsTransport(http.DefaultTransport),
+		})
+		tc := oauth2.NewClient(ctx, ts)
 		githubCli := github.NewClient(tc)
-		svc.cli = client.NewGithubClient(githubCli)
+		auth.cli = client.NewGithubClient(githubCli)
+
+		user, _, err := githubCli.Users.Get(context.Background(), "")
+		if err != nil {
+			return nil, fmt.Errorf("get authenticated user error: %v", err)
+		}
+		auth.botLogin = user.GetLogin()
 	} else if cfg.GithubAppPrivateKey != "" {
-		tr, err := githubapp.NewGithubAppInstallTransport(http.DefaultTransport, cfg.GithubAppID, cfg.GithubAppPrivateKey)
+		tr, err := githubapp.NewGithubAppInstallTransport(client.NewMetricsTransport(http.DefaultTransport), cfg.GithubAppID, cfg.GithubAppPrivateKey)
 		if err != nil {
 			return nil, err
 		}
 
 		githubCli := github.NewClient(&http.Client{Transport: tr})
-		svc.cli = client.NewGithubClient(githubCli)
-		requireInstallation = true
+		auth.cli = client.NewGithubClient(githubCli)
+		auth.appTransport = tr
+		auth.botLogin = tr.BotLogin()
 	}
+	return auth, nil
+}
 
-	svc.staticRepoConfs = cfg.RepoConfs
-	if svc.RepoConfDir != "" {
-		extraRepoConfs, err := svc.loadRepoConfsFromDir(svc.RepoConfDir)
+func newPluginsEnv(cfg Config, auth *githubAuth) pluginsEnv {
+	return pluginsEnv{
+		cli:                         auth.cli,
+		resolver:                    newRepoConfResolver(cfg.Templates, cfg.OrgDefaults),
+		inRepoConf:                  cfg.InRepoConf,
+		inRepoConfPrecedence:        cfg.InRepoConfPrecedence,
+		inRepoConfPlugins:           cfg.InRepoConfPlugins,
+		inRepoConfUnconfiguredRepos: cfg.InRepoConfUnconfiguredRepos,
+		teams:                       team.NewCache(auth.cli, time.Duration(cfg.TeamCacheTTLS)*time.Second),
+	}
+}
+
+// SetConfigLoader sets the function reading the config file, the config is reloaded by it when SIGHUP is received.
+func (svc *Service) SetConfigLoader(loader func() (Config, error)) {
+	svc.configLoader = loader
+}
+
+func (svc *Service) currentEnv() pluginsEnv {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+	return svc.env
+}
+
+func (svc *Service) adminToken() string {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+	return svc.AdminToken
+}
+
+// Run serves webhook deliveries until an error occurs or SIGTERM/SIGINT is received,
+// in flight deliveries are drained for at most shutdown_timeout_s before returning.
+func (svc *Service) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go svc.updatePluginsWorker(ctx)
+
+	queueDone := make(chan struct{})
+	if svc.queue != nil {
+		go func() {
+			svc.queue.Run(ctx, svc.handleDelivery)
+			close(queueDone)
+		}()
+	} else {
+		close(queueDone)
+	}
+
+	errCh := make(chan error, 2)
+	servers := make([]*http.Server, 0, 2)
+
+	server := svc.newHTTPServer(svc.BindAddr, http.HandlerFunc(svc.Handler))
+	servers = append(servers, server)
+	if svc.TLSCertFile != "" && svc.TLSKeyFile != "" {
+		certReloader, err := httputil.NewCertReloader(svc.TLSCertFile, svc.TLSKeyFile, 10*time.Second)
 		if err != nil {
-			return nil, fmt.Errorf("load repo confs from dir error: %v", err)
+			return fmt.Errorf("load tls certificate error: %v", err)
+		}
+		server.TLSConfig = &tls.Config{
+			GetCertificate: certReloader.GetCertificate,
 		}
 
-		svc.extraRepoConfs = extraRepoConfs
+		go func() {
+			log.Info("freebot listen on %s with tls", svc.BindAddr)
+			errCh <- server.ListenAndServeTLS("", "")
+		}()
+	} else {
+		go func() {
+			log.Info("freebot listen on %s", svc.BindAddr)
+			errCh <- server.ListenAndServe()
+		}()
 	}
-	repoConfs := svc.mergeRepoConfsTo(nil, svc.staticRepoConfs)
-	repoConfs = svc.mergeRepoConfsTo(repoConfs, svc.extraRepoConfs)
-	plugins, err := svc.createPlugins(repoConfs)
-	if err != nil {
-		return nil, fmt.Errorf("create plugins error: %v", err)
+
+	if svc.AdminBindAddr != "" {
+		if svc.AdminToken == "" {
+			log.Warn("admin_token is empty, admin api on %s is not protected", svc.AdminBindAddr)
+		}
+		adminServer := svc.newHTTPServer(svc.AdminBindAddr, svc.AdminHandler())
+		servers = append(servers, adminServer)
+		go func() {
+			log.Info("freebot admin api listen on %s", svc.AdminBindAddr)
+			errCh <- adminServer.ListenAndServe()
+		}()
 	}
 
-	svc.eventHandler = NewEventHandler(requireInstallation, plugins)
-	return svc, nil
-}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
 
-func (svc *Service) Run() error {
-	go svc.updatePluginsWorker()
+	var err error
+	for running := true; running; {
+		select {
+		case err = <-errCh:
+			log.Error("freebot listen error: %v", err)
+			running = false
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				log.Info("receive signal [%v], reload config", sig)
+				if reloadErr := svc.ReloadConfig(); reloadErr != nil {
+					log.Error("reload config error: %v, old config is still used", reloadErr)
+				}
+				continue
+			}
+			log.Info("receive signal [%v], shutting down", sig)
+			running = false
+		case <-svc.stopCh:
+			log.Info("freebot is stopped, shutting down")
+			running = false
+		}
+	}
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Duration(svc.ShutdownTimeoutS)*time.Second)
+	defer shutdownCancel()
+
+	// stop accepting deliveries and wait for in flight handlers
+	for _, s := range servers {
+		if shutdownErr := s.Shutdown(shutdownCtx); shutdownErr != nil {
+			log.Warn("shutdown http server on %s error: %v", s.Addr, shutdownErr)
+		}
+	}
 
-	log.Info("freebot listen on %s", svc.BindAddr)
-	err := http.ListenAndServe(svc.BindAddr, http.HandlerFunc(svc.Handler))
+	// stop workers, deliveries in queue are handled after restarting
+	cancel()
+	select {
+	case <-queueDone:
+	case <-shutdownCtx.Done():
+		log.Warn("shutdown timeout, some deliveries in queue are not finished")
+	}
+	log.Info("freebot exit")
 	return err
 }
 
+// Stop makes Run return after draining in flight deliveries.
+func (svc *Service) Stop() {
+	svc.stopOnce.Do(func() {
+		close(svc.stopCh)
+	})
+}
+
+func (svc *Service) newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: time.Duration(svc.ReadHeaderTimeoutS) * time.Second,
+		ReadTimeout:       time.Duration(svc.ReadTimeoutS) * time.Second,
+		WriteTimeout:      time.Duration(svc.WriteTimeoutS) * time.Second,
+		IdleTimeout:       time.Duration(svc.IdleTimeoutS) * time.Second,
+	}
+}
+
 func (svc *Service) Handler(w http.ResponseWriter, r *http.Request) {
 	eventType := r.Header.Get("X-Github-Event")
 	if eventType == "" {
@@ -145,7 +559,8 @@ func (svc *Service) Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Debug("event [%s], id [%s]", eventType, r.Header.Get("X-GitHub-Delivery"))
+	deliveryID := r.Header.Get("X-GitHub-Delivery")
+	log.Debug("event [%s], id [%s]", eventType, deliveryID)
 
 	content, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -154,114 +569,588 @@ func (svc *Service) Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = svc.eventHandler.HandleEvent(r.Context(), eventType, string(content))
+	err = svc.verifySignature(r, content)
+	if err != nil {
+		log.Warn("event [%s], id [%s] verify signature error: %v", eventType, deliveryID, err)
+		httputil.ReplyError(w, ErrInvalidSignature)
+		return
+	}
+
+	if deliveryID != "" {
+		if token := svc.adminToken(); token != "" &&
+			subtle.ConstantTimeCompare([]byte(r.Header.Get(HeaderForceReprocess)), []byte(token)) == 1 {
+			log.Info("event [%s], id [%s] force reprocess", eventType, deliveryID)
+			svc.dedup.Remove(deliveryID)
+		}
+		if svc.dedup.CheckAndAdd(deliveryID) {
+			log.Info("event [%s], id [%s] is a duplicate delivery, ignored", eventType, deliveryID)
+			w.WriteHeader(200)
+			return
+		}
+	}
+
+	repo, action := parseEventMeta(content)
+	metrics.EventsReceived.Inc(eventType, action, svc.metricsRepo(repo))
+
+	// push events are only used to reload in-repo confs, they are not handled by plugins
+	if eventType == event.EvPush {
+		result, err := svc.handlePushEvent(content)
+		if err != nil {
+			log.Warn("handle push event error: %v", err)
+			httputil.ReplyError(w, err)
+			return
+		}
+		httputil.ReplyJSON(w, 200, result)
+		return
+	}
+
+	// membership and team events are only used to refresh members of teams in roles
+	if eventType == event.EvMembership || eventType == event.EvTeam {
+		result, err := svc.handleTeamEvent(eventType, content)
+		if err != nil {
+			log.Warn("handle %s event error: %v", eventType, err)
+			httputil.ReplyError(w, err)
+			return
+		}
+		httputil.ReplyJSON(w, 200, result)
+		return
+	}
+
+	if svc.queue != nil {
+		err = svc.queue.Push(&queue.Delivery{
+			ID:        deliveryID,
+			EventType: eventType,
+			Payload:   string(content),
+		})
+		if err != nil {
+			log.Error("push event [%s] to queue error: %v", eventType, err)
+			svc.dedup.Remove(deliveryID)
+			httputil.ReplyError(w, httputil.NewHttpError(500, "save event error"))
+			return
+		}
+		w.WriteHeader(202)
+		return
+	}
+
+	result, err := svc.eventHandler.HandleEvent(r.Context(), eventType, string(content))
 	if err != nil {
 		log.Warn("handle event error: %v", err)
-		httputil.ReplyError(w, err)
+		if _, ok := err.(*PluginsError); ok {
+			// plugins succeeded should not do their operations twice, so the redelivery from github is ignored
+			// and only failed plugins are run again by replaying the saved event
+			svc.saveFailedEvent(deliveryID, eventType, string(content), err)
+		} else {
+			// no plugin has run, let the redelivery from github be processed
+			svc.dedup.Remove(deliveryID)
+		}
+		if result != nil {
+			httputil.ReplyJSON(w, 500, result)
+		} else {
+			httputil.ReplyError(w, err)
+		}
 		return
 	}
 
-	w.WriteHeader(200)
+	httputil.ReplyJSON(w, 200, result)
 }
 
-func (svc *Service) loadRepoConfsFromDir(path string) (map[string]RepoConf, error) {
-	files, err := ioutil.ReadDir(path)
+// handleDelivery is called by queue workers, returned error means the delivery should be retried.
+func (svc *Service) handleDelivery(ctx context.Context, d *queue.Delivery) error {
+	log.Debug("event [%s], id [%s] handled by worker, attempts [%d]", d.EventType, d.ID, d.Attempts)
+
+	_, err := svc.eventHandler.HandleEventWithPlugins(ctx, d.EventType, d.Payload, d.Plugins)
+	if err != nil {
+		// request errors won't be fixed by retrying
+		if e, ok := err.(*httputil.HttpError); ok && e.Code() < 500 {
+			log.Warn("event [%s], id [%s] handle event error: %v", d.EventType, d.ID, err)
+			return nil
+		}
+		// plugins succeeded should not do their operations twice
+		if e, ok := err.(*PluginsError); ok {
+			d.Plugins = e.Plugins()
+		}
+		log.Warn("event [%s], id [%s] handle event error, will retry: %v", d.EventType, d.ID, err)
+		return err
+	}
+	return nil
+}
+
+// saveFailedEvent saves the event to dead letter store if some plugins failed.
+func (svc *Service) saveFailedEvent(deliveryID string, eventType string, content string, err error) {
+	pluginsErr, ok := err.(*PluginsError)
+	if !ok {
+		return
+	}
+	if svc.deadLetters == nil {
+		log.Warn("failed event [%s], id [%s] is not saved because dead_letter_dir is not set", eventType, deliveryID)
+		return
+	}
+
+	entry := &deadletter.Entry{
+		DeliveryID: deliveryID,
+		EventType:  eventType,
+		Repo:       parseRepoFullName([]byte(content)),
+		Payload:    content,
+		Errors:     make(map[string]string),
+	}
+	for _, v := range pluginsErr.Errors {
+		entry.Errors[v.Plugin] = v.Err.Error()
+	}
+	if err = svc.deadLetters.Save(entry); err != nil {
+		log.Error("save failed event [%s], id [%s] error: %v", eventType, deliveryID, err)
+		return
+	}
+	log.Info("failed event [%s], id [%s] saved as [%s]", eventType, deliveryID, entry.ID)
+}
+
+// ListFailedEvents returns events saved in dead letter store without payload.
+func (svc *Service) ListFailedEvents() ([]*deadletter.Entry, error) {
+	if svc.deadLetters == nil {
+		return nil, ErrNoDeadLetterStore
+	}
+	return svc.deadLetters.List()
+}
+
+// ReplayFailedEvent runs the plugins failed in the saved event again,
+// the event is removed from dead letter store if all of them succeed.
+func (svc *Service) ReplayFailedEvent(ctx context.Context, id string) (*deadletter.Entry, error) {
+	if svc.deadLetters == nil {
+		return nil, ErrNoDeadLetterStore
+	}
+
+	entry, err := svc.deadLetters.Get(id)
 	if err != nil {
 		return nil, err
 	}
 
-	out := make(map[string]RepoConf)
-	for _, file := range files {
-		if !file.IsDir() {
-			fpath := filepath.Join(path, file.Name())
-			buf, err := ioutil.ReadFile(fpath)
-			if err != nil {
-				return nil, err
-			}
+	log.Info("replay failed event [%s], plugins %v", id, entry.FailedPlugins())
+	_, err = svc.eventHandler.HandleEventWithPlugins(ctx, entry.EventType, entry.Payload, entry.FailedPlugins())
+	if err == nil {
+		entry.Errors = make(map[string]string)
+		return entry, svc.deadLetters.Remove(id)
+	}
 
-			tmp := make(map[string]RepoConf)
-			err = json.Unmarshal(buf, &tmp)
-			if err != nil {
-				return nil, fmt.Errorf("parse file [%s] error: %v", fpath, err)
-			}
-			out = svc.mergeRepoConfsTo(out, tmp)
+	if pluginsErr, ok := err.(*PluginsError); ok {
+		entry.Errors = make(map[string]string)
+		for _, v := range pluginsErr.Errors {
+			entry.Errors[v.Plugin] = v.Err.Error()
 		}
 	}
-	return out, nil
+	entry.ReplayCount++
+	if saveErr := svc.deadLetters.Save(entry); saveErr != nil {
+		log.Error("save failed event [%s] error: %v", id, saveErr)
+	}
+	return entry, err
+}
+
+func parseRepoFullName(content []byte) string {
+	repo, _ := parseEventMeta(content)
+	return repo
+}
+
+// parseEventMeta returns full name of the repo and action in webhook payload,
+// the repo is the one events are routed to, see payloadRepo.
+func parseEventMeta(content []byte) (repo string, action string) {
+	payload := struct {
+		Action string             `json:"action"`
+		Repo   *github.Repository `json:"repository"`
+	}{}
+	json.Unmarshal(content, &payload)
+	repo, _ = payloadRepo(payload.Repo)
+	return repo, payload.Action
 }
 
-func (svc *Service) mergeRepoConfsTo(dst map[string]RepoConf, src map[string]RepoConf) map[string]RepoConf {
-	if dst == nil {
-		dst = make(map[string]RepoConf)
+// payloadRepo returns owner/name of the repo which events are routed to by owner login and name.
+// Owner in push events has no login field, its name is used instead.
+// ok is false if full_name in the payload names another repo.
+func payloadRepo(r *github.Repository) (fullName string, ok bool) {
+	owner := r.GetOwner().GetLogin()
+	if owner == "" {
+		owner = r.GetOwner().GetName()
 	}
-	for k, v := range src {
-		dst[k] = v
+	if owner == "" || r.GetName() == "" {
+		return r.GetFullName(), true
 	}
-	return dst
+	fullName = owner + "/" + r.GetName()
+	return fullName, r.GetFullName() == "" || strings.EqualFold(r.GetFullName(), fullName)
 }
 
-func (svc *Service) createPlugins(repoConfs map[string]RepoConf) (plugins map[string][]plugin.Plugin, err error) {
-	plugins = make(map[string][]plugin.Plugin)
-	for repoName, repoConf := range repoConfs {
-		log.Info("repo [%s] alias: %+v", repoName, repoConf.Alias)
-		log.Info("repo [%s] roles: %+v", repoName, repoConf.Roles)
+// metricsRepo returns the repo used as the metrics label, repos matching no repo conf or org default
+// share one placeholder so unauthenticated payloads can't create unlimited label values.
+func (svc *Service) metricsRepo(fullName string) string {
+	svc.mu.RLock()
+	_, _, ok := matchRepoConf(svc.repoConfs, fullName)
+	env := svc.env
+	svc.mu.RUnlock()
+	if ok {
+		return fullName
+	}
+	if arrs := strings.SplitN(fullName, "/", 2); len(arrs) == 2 && env.resolver != nil {
+		if _, hasDefault, err := env.resolver.OrgDefault(arrs[0]); err == nil && hasDefault {
+			return fullName
+		}
+	}
+	return metricsUnknownRepo
+}
 
-		for pluginName, pluginConf := range repoConf.Plugins {
-			if pluginConf.Disable {
-				continue
+// ignoreBotSenders returns true if ignore_bot_senders is enabled in conf of the repo.
+func (svc *Service) ignoreBotSenders(owner, repo string) bool {
+	svc.mu.RLock()
+	defer svc.mu.RUnlock()
+	repoConf, _, ok := matchRepoConf(svc.repoConfs, owner+"/"+repo)
+	return ok && repoConf.IgnoreBotSenders
+}
+
+// verifySignature checks the webhook signature with the secret of the repo in payload,
+// deliveries are accepted without check if no secret is configured.
+// Deliveries whose repository full_name doesn't match its owner and name are always rejected.
+func (svc *Service) verifySignature(r *http.Request, content []byte) error {
+	payload := struct {
+		Repo *github.Repository `json:"repository"`
+	}{}
+	json.Unmarshal(content, &payload)
+	// the secret must be the one of the repo the event is routed to
+	fullName, ok := payloadRepo(payload.Repo)
+	if !ok {
+		return fmt.Errorf("full_name [%s] of the repository doesn't match its owner and name [%s]",
+			payload.Repo.GetFullName(), fullName)
+	}
+
+	svc.mu.RLock()
+	secret := svc.WebhookSecret
+	allowSHA1 := svc.WebhookAllowSHA1
+	if repoConf, _, ok := matchRepoConf(svc.repoConfs, fullName); ok && repoConf.WebhookSecret != "" {
+		secret = repoConf.WebhookSecret
+	}
+	svc.mu.RUnlock()
+
+	if secret == "" {
+		return nil
+	}
+	return webhook.VerifySignature([]byte(secret), content, r.Header.Get(webhook.HeaderSignature256),
+		r.Header.Get(webhook.HeaderSignature), allowSHA1)
+}
+
+// loadRepoConfsFromDir returns repo confs of each file in dir, the last good confs in lastGood are used
+// for files failing to load. Errors of files are returned in errs.
+func (svc *Service) loadRepoConfsFromDir(dir string, lastGood map[string]map[string]RepoConf) (
+	files map[string]map[string]RepoConf, errs map[string]*RepoConfError, err error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files = make(map[string]map[string]RepoConf)
+	errs = make(map[string]*RepoConfError)
+	for _, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+
+		fpath := filepath.Join(dir, info.Name())
+		tmp := make(map[string]RepoConf)
+		if err = config.LoadFile(fpath, &tmp); err != nil {
+			last, ok := lastGood[fpath]
+			if ok {
+				files[fpath] = last
 			}
+			errs[fpath] = newRepoConfError(fpath, err, ok)
+			continue
+		}
+		files[fpath] = tmp
+	}
+	return files, errs, nil
+}
+
+// buildRepoConfs merges repo_confs and confs of files in repo_conf_dir in the order of file names,
+// then resolves their templates and org defaults. Confs failing to merge or resolve are returned in errs,
+// their last good confs in lastGood are used if exist.
+func (svc *Service) buildRepoConfs(resolver *repoConfResolver, staticRepoConfs map[string]RepoConf,
+	files map[string]map[string]RepoConf, lastGood map[string]RepoConf) (
+	out map[string]RepoConf, errs map[string]*RepoConfError, err error) {
+	if err = resolver.Check(); err != nil {
+		return nil, nil, err
+	}
 
-			arrs := strings.Split(repoName, "/")
-			if len(arrs) < 2 {
-				return nil, fmt.Errorf("repo name invalid")
+	all := make(map[string]RepoConf, len(staticRepoConfs))
+	for k, v := range staticRepoConfs {
+		all[k] = v
+	}
+	mergeErrs := make(map[string]error)
+	fileNames := make([]string, 0, len(files))
+	for name := range files {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+	for _, name := range fileNames {
+		for k, v := range files[name] {
+			if exist, ok := all[k]; ok {
+				merged, err := mergeRepoConf(exist, v)
+				if err != nil {
+					mergeErrs[k] = fmt.Errorf("merge conf in [%s] error: %v", name, err)
+					continue
+				}
+				v = merged
 			}
-			baseOptions := plugin.PluginOptions{}
-			baseOptions.Complete(arrs[0], arrs[1], repoConf.Alias, repoConf.Roles, repoConf.LabelRoles, pluginConf.Preconditions, pluginConf.Extra)
-			p, err := plugin.Create(svc.cli, svc.notifier, pluginName, baseOptions)
-			if err != nil {
-				err = fmt.Errorf("create plugin [%s] error: %v", pluginName, err)
-				log.Error("%v", err)
-				return nil, err
+			all[k] = v
+		}
+	}
+
+	out = make(map[string]RepoConf, len(all))
+	errs = make(map[string]*RepoConfError)
+	for k, v := range all {
+		err := mergeErrs[k]
+		if err == nil {
+			resolved, resolveErr := resolver.Resolve(k, v)
+			if resolveErr == nil {
+				out[k] = resolved
+				continue
 			}
+			err = fmt.Errorf("resolve conf error: %v", resolveErr)
+		}
 
-			ps, ok := plugins[repoName]
-			if ok {
-				ps = append(ps, p)
-			} else {
-				ps = make([]plugin.Plugin, 1)
-				ps[0] = p
+		last, ok := lastGood[k]
+		if ok {
+			out[k] = last
+		}
+		errs[k] = newRepoConfError(k, err, ok)
+	}
+	return out, errs, nil
+}
+
+// createPlugins creates plugins for repo confs with exact owner/repo key,
+// plugins of repos matching patterns are created by PluginsCreator when needed.
+// If in_repo_conf is enabled, all plugins are created by PluginsCreator because in-repo confs
+// can only be read with the installation of events.
+// Repos failing to create plugins keep their last good plugins and are returned in errs.
+func (svc *Service) createPlugins(env pluginsEnv, repoConfs map[string]RepoConf) (
+	plugins map[string][]plugin.Plugin, errs map[string]*RepoConfError) {
+	plugins = make(map[string][]plugin.Plugin)
+	errs = make(map[string]*RepoConfError)
+	for repoName, repoConf := range repoConfs {
+		if isRepoPattern(repoName) {
+			if _, err := path.Match(repoName, ""); err != nil {
+				errs[repoName] = newRepoConfError(repoName, fmt.Errorf("repo pattern invalid: %v", err), false)
+				continue
 			}
+			log.Info("repo pattern [%s] plugins will be created when matched", repoName)
+			continue
+		}
+
+		arrs := strings.Split(repoName, "/")
+		if len(arrs) != 2 || arrs[0] == "" || arrs[1] == "" {
+			errs[repoName] = newRepoConfError(repoName, fmt.Errorf("repo name invalid"), false)
+			continue
+		}
+
+		if env.inRepoConf {
+			continue
+		}
+
+		ps, confErr := svc.buildRepoPlugins(env, arrs[0], arrs[1], repoName, repoConf)
+		if confErr != nil {
+			errs[repoName] = confErr
+		}
+		if ps != nil {
 			plugins[repoName] = ps
 		}
 	}
+
+	// plugins of repos no longer configured are not kept
+	svc.goodPlugins.Prune(func(repo string) bool {
+		_, _, ok := matchRepoConf(repoConfs, repo)
+		return ok || env.inRepoConf
+	})
+	return plugins, errs
+}
+
+// buildRepoPlugins creates plugins of the repo, the last good plugins are returned with the error if it fails.
+func (svc *Service) buildRepoPlugins(env pluginsEnv, owner, repo string, confKey string, repoConf RepoConf) (
+	[]plugin.Plugin, *RepoConfError) {
+	repoName := owner + "/" + repo
+	plugins, err := svc.createRepoPlugins(env, owner, repo, confKey, repoConf)
+	if err == nil {
+		svc.goodPlugins.Set(repoName, plugins)
+		return plugins, nil
+	}
+
+	last, ok := svc.goodPlugins.Get(repoName)
+	return last, newRepoConfError(repoName, err, ok)
+}
+
+func (svc *Service) createRepoPlugins(env pluginsEnv, owner, repo string, confKey string, repoConf RepoConf) ([]plugin.Plugin, error) {
+	repoName := owner + "/" + repo
+	if confKey != repoName {
+		log.Info("repo [%s] resolved to conf [%s]", repoName, confKey)
+	}
+	log.Info("repo [%s] alias: %v", repoName, config.RedactValue(repoConf.Alias))
+	log.Info("repo [%s] roles: %v", repoName, config.RedactValue(repoConf.Roles))
+
+	plugins := make([]plugin.Plugin, 0)
+	names := make([]string, 0)
+	for _, pluginName := range sortPluginNames(repoConf.Plugins) {
+		pluginConf := repoConf.Plugins[pluginName]
+		if pluginConf.Disable {
+			continue
+		}
+
+		baseOptions := plugin.PluginOptions{}
+		baseOptions.Complete(owner, repo, repoConf.Alias, repoConf.Roles, repoConf.LabelRoles, pluginConf.Preconditions, pluginConf.Extra)
+		baseOptions.Teams = env.teams
+		cli, notifier := env.cli, svc.notifier
+		if repoConf.DryRun || pluginConf.DryRun {
+			log.Info("repo [%s] plugin [%s] runs in dry-run mode", repoName, pluginName)
+			baseOptions.DryRun = true
+			cli, notifier = client.NewDryRunClient(cli), notify.NewDryRunNotifier()
+		}
+		baseOptions.Feedback = plugin.NewFeedback(cli, repoConf.Feedback, svc.feedbackLimiter)
+		p, err := plugin.Create(cli, notifier, pluginName, baseOptions)
+		if err != nil {
+			return nil, fmt.Errorf("create plugin [%s] error: %v", pluginName, err)
+		}
+		plugins = append(plugins, p)
+		names = append(names, pluginName)
+	}
+	for _, p := range plugins {
+		if v, ok := p.(plugin.PluginsAwareInterface); ok {
+			v.SetPlugins(plugins)
+		}
+	}
+	log.Info("repo [%s] plugins in order: %v", repoName, names)
 	return plugins, nil
 }
 
-func (svc *Service) updatePluginsWorker() {
+// sortPluginNames returns plugin names ordered by priority desc, names with the same priority are ordered by name.
+func sortPluginNames(plugins map[string]PluginConfig) []string {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := plugins[names[i]].GetPriority(names[i]), plugins[names[j]].GetPriority(names[j])
+		if pi != pj {
+			return pi > pj
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// newPluginsCreator returns a PluginsCreator creating plugins for repos matching patterns in repoConfs,
+// or for repos having in-repo confs.
+func (svc *Service) newPluginsCreator(env pluginsEnv, repoConfs map[string]RepoConf) PluginsCreator {
+	return func(ctx context.Context, owner, repo string) ([]plugin.Plugin, error) {
+		repoConf, confKey, ok, err := svc.effectiveRepoConf(ctx, env, repoConfs, owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrNoPlugins
+		}
+
+		plugins, confErr := svc.buildRepoPlugins(env, owner, repo, confKey, repoConf)
+		if confErr == nil {
+			svc.confErrors.Clear(owner + "/" + repo)
+			return plugins, nil
+		}
+		svc.confErrors.Set(confErr)
+		if plugins == nil {
+			return nil, fmt.Errorf("%s", confErr.Error)
+		}
+		return plugins, nil
+	}
+}
+
+func (svc *Service) updatePluginsWorker(ctx context.Context) {
+	if svc.RepoConfDir == "" {
+		return
+	}
+
+	w, err := watcher.NewDirWatcher(svc.RepoConfDir, time.Duration(svc.RepoConfDirDebounceMS)*time.Millisecond)
+	if err != nil {
+		log.Warn("watch repo_conf_dir [%s] error: %v, poll it every %d seconds", svc.RepoConfDir, err, svc.RepoConfDirUpdateIntervalS)
+		svc.pollRepoConfDir(ctx)
+		return
+	}
+	defer w.Close()
+
 	for {
-		time.Sleep(time.Duration(svc.RepoConfDirUpdateIntervalS) * time.Second)
-		if svc.RepoConfDir != "" {
-			repoConfs, err := svc.loadRepoConfsFromDir(svc.RepoConfDir)
-			if err != nil {
-				log.Error("load repo confs from dir error: %v", err)
-				continue
-			}
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.C:
+		}
 
-			if !reflect.DeepEqual(svc.extraRepoConfs, repoConfs) {
-				log.Info("repo confs changed...")
-				all := svc.mergeRepoConfsTo(nil, repoConfs)
-				svc.mergeRepoConfsTo(all, svc.staticRepoConfs)
-				plugins, err := svc.createPlugins(all)
-				if err != nil {
-					log.Error("create plugins error: %v", err)
-					continue
-				}
+		if _, err := svc.ReloadRepoConfs(false); err != nil {
+			log.Error("%v", err)
+		}
+	}
+}
 
-				svc.eventHandler.UpdatePlugins(plugins)
-				log.Info("update plugins success")
+func (svc *Service) pollRepoConfDir(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(svc.RepoConfDirUpdateIntervalS) * time.Second)
+	defer ticker.Stop()
 
-				svc.extraRepoConfs = repoConfs
-			}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := svc.ReloadRepoConfs(false); err != nil {
+			log.Error("%v", err)
+		}
+	}
+}
+
+// ReloadRepoConfs loads repo confs from repo_conf_dir and recreates plugins if confs changed or force is true.
+// Repos failing to load keep their last good confs and plugins, others are updated.
+func (svc *Service) ReloadRepoConfs(force bool) (changed bool, err error) {
+	svc.reloadMu.Lock()
+	defer svc.reloadMu.Unlock()
+
+	var (
+		files    map[string]map[string]RepoConf
+		fileErrs map[string]*RepoConfError
+	)
+	if svc.RepoConfDir != "" {
+		files, fileErrs, err = svc.loadRepoConfsFromDir(svc.RepoConfDir, svc.repoConfFiles)
+		if err != nil {
+			return false, fmt.Errorf("load repo confs from dir error: %v", err)
 		}
 	}
+
+	if !force && reflect.DeepEqual(svc.repoConfFiles, files) && reflect.DeepEqual(svc.repoConfFileErrs, fileErrs) {
+		return false, nil
+	}
+
+	log.Info("repo confs changed...")
+	env := svc.currentEnv()
+	svc.mu.RLock()
+	old := svc.repoConfs
+	svc.mu.RUnlock()
+
+	all, confErrs, err := svc.buildRepoConfs(env.resolver, svc.staticRepoConfs, files, old)
+	if err != nil {
+		return false, err
+	}
+	plugins, pluginErrs := svc.createPlugins(env, all)
+
+	svc.eventHandler.UpdatePlugins(plugins, svc.newPluginsCreator(env, all))
+	svc.mu.Lock()
+	svc.repoConfs = all
+	svc.mu.Unlock()
+	errs := mergeRepoConfErrors(fileErrs, confErrs, pluginErrs)
+	svc.confErrors.Reset(errs)
+	logRepoConfsDiff(old, all)
+	if len(errs) > 0 {
+		log.Warn("update plugins done, %d repo confs or files failed", len(errs))
+	} else {
+		log.Info("update plugins success")
+	}
+
+	svc.repoConfFiles = files
+	svc.repoConfFileErrs = fileErrs
+	return true, nil
 }
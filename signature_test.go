@@ -0,0 +1,83 @@
+package freebot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fatedier/freebot/pkg/webhook"
+)
+
+func signedRequest(t *testing.T, secret string, payload string) *http.Request {
+	r, err := http.NewRequest("POST", "/", strings.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	r.Header.Set(webhook.HeaderSignature256, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestVerifySignatureRepoSecret(t *testing.T) {
+	svc := &Service{
+		repoConfs: map[string]RepoConf{
+			"org/target": {WebhookSecret: "target-secret"},
+			"org/other":  {WebhookSecret: "other-secret"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		payload string
+		wantErr bool
+	}{
+		{
+			name:    "secret of the repo",
+			secret:  "target-secret",
+			payload: `{"repository": {"full_name": "org/target", "name": "target", "owner": {"login": "org"}}}`,
+		},
+		{
+			name:    "secret of another repo",
+			secret:  "other-secret",
+			payload: `{"repository": {"full_name": "org/target", "name": "target", "owner": {"login": "org"}}}`,
+			wantErr: true,
+		},
+		{
+			// events are routed by owner and name, full_name can't select the secret
+			name:    "forged full_name",
+			secret:  "other-secret",
+			payload: `{"repository": {"full_name": "org/other", "name": "target", "owner": {"login": "org"}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "forged full_name of a repo without secret",
+			secret:  "",
+			payload: `{"repository": {"full_name": "org/nosecret", "name": "target", "owner": {"login": "org"}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "push event with owner name",
+			secret:  "target-secret",
+			payload: `{"repository": {"full_name": "org/target", "name": "target", "owner": {"name": "org"}}}`,
+		},
+		{
+			name:    "repo without secret",
+			secret:  "",
+			payload: `{"repository": {"full_name": "org/nosecret", "name": "nosecret", "owner": {"login": "org"}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := svc.verifySignature(signedRequest(t, tt.secret, tt.payload), []byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifySignature error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
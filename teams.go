@@ -0,0 +1,54 @@
+package freebot
+
+import (
+	"encoding/json"
+
+	"github.com/fatedier/freebot/pkg/event"
+	"github.com/fatedier/freebot/pkg/log"
+
+	"github.com/google/go-github/github"
+)
+
+// handleTeamEvent drops cached members of the team changed by membership or team events,
+// all teams of the org are dropped by team events since the slug may be changed.
+func (svc *Service) handleTeamEvent(eventType string, content []byte) (*EventResult, error) {
+	var (
+		action string
+		org    string
+		slug   string
+	)
+	switch eventType {
+	case event.EvMembership:
+		ev := &github.MembershipEvent{}
+		if err := json.Unmarshal(content, ev); err != nil {
+			return nil, ErrEventPayload
+		}
+		action, org, slug = ev.GetAction(), ev.GetOrg().GetLogin(), ev.GetTeam().GetSlug()
+	case event.EvTeam:
+		ev := &github.TeamEvent{}
+		if err := json.Unmarshal(content, ev); err != nil {
+			return nil, ErrEventPayload
+		}
+		action, org = ev.GetAction(), ev.GetOrg().GetLogin()
+	}
+
+	result := &EventResult{
+		Event:   eventType,
+		Action:  action,
+		Plugins: make([]PluginResult, 0),
+	}
+	if org == "" {
+		result.Ignored = "no organization info"
+		return result, nil
+	}
+
+	teams := svc.currentEnv().teams
+	if slug != "" {
+		log.Info("team [%s/%s] %s event [%s], drop cached members", org, slug, eventType, action)
+		teams.Invalidate(org, slug)
+	} else {
+		log.Info("org [%s] %s event [%s], drop cached members of all teams", org, eventType, action)
+		teams.InvalidateOrg(org)
+	}
+	return result, nil
+}
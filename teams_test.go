@@ -0,0 +1,88 @@
+package freebot
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fatedier/freebot/pkg/team"
+)
+
+// countingLister returns members of teams and counts how many times each team is listed.
+type countingLister struct {
+	members map[string][]string
+	counts  map[string]int
+	mu      sync.Mutex
+}
+
+func (l *countingLister) ListTeamMembers(ctx context.Context, org, slug string) ([]string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[org+"/"+slug]++
+	return l.members[org+"/"+slug], nil
+}
+
+func (l *countingLister) count(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[key]
+}
+
+func TestHandleTeamEventInvalidates(t *testing.T) {
+	tests := []struct {
+		name      string
+		eventType string
+		payload   string
+		// teams listed again after the event
+		want map[string]int
+	}{
+		{
+			name:      "membership drops the team",
+			eventType: "membership",
+			payload:   `{"action": "added", "scope": "team", "team": {"slug": "dev"}, "organization": {"login": "org"}}`,
+			want:      map[string]int{"org/dev": 2, "org/qa": 1, "other/dev": 1},
+		},
+		{
+			name:      "team drops all teams of the org",
+			eventType: "team",
+			payload:   `{"action": "deleted", "organization": {"login": "org"}}`,
+			want:      map[string]int{"org/dev": 2, "org/qa": 2, "other/dev": 1},
+		},
+		{
+			name:      "no organization",
+			eventType: "team",
+			payload:   `{"action": "deleted"}`,
+			want:      map[string]int{"org/dev": 1, "org/qa": 1, "other/dev": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lister := &countingLister{
+				members: map[string][]string{"org/dev": {"alice"}, "org/qa": {"alice"}, "other/dev": {"alice"}},
+				counts:  make(map[string]int),
+			}
+			svc := &Service{env: pluginsEnv{teams: team.NewCache(lister, time.Hour)}}
+			isMembers := func() {
+				for _, key := range []struct{ org, slug string }{{"org", "dev"}, {"org", "qa"}, {"other", "dev"}} {
+					if ok, err := svc.env.teams.IsMember(context.Background(), key.org, key.slug, "alice"); err != nil || !ok {
+						t.Fatalf("IsMember(%s/%s) = %v, %v", key.org, key.slug, ok, err)
+					}
+				}
+			}
+
+			isMembers()
+			if _, err := svc.handleTeamEvent(tt.eventType, []byte(tt.payload)); err != nil {
+				t.Fatalf("handleTeamEvent error: %v", err)
+			}
+			isMembers()
+
+			for key, n := range tt.want {
+				if got := lister.count(key); got != n {
+					t.Errorf("members of %s listed %d times, want %d", key, got, n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package freebot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/fatedier/freebot/pkg/config"
+	"github.com/fatedier/freebot/plugin"
+)
+
+// ConfigProblem is a problem found by ValidateConfigFile, Path is the location of the value in File.
+type ConfigProblem struct {
+	File    string `json:"file"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (p ConfigProblem) String() string {
+	if p.Path == "" {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.File, p.Path, p.Message)
+}
+
+type configValidator struct {
+	problems []ConfigProblem
+}
+
+func (v *configValidator) addf(file string, path string, format string, args ...interface{}) {
+	v.problems = append(v.problems, ConfigProblem{
+		File:    file,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// repoConfLocation is where a repo conf is defined, it is the last file if the conf is merged from more than one file.
+type repoConfLocation struct {
+	file string
+	path string
+}
+
+// ValidateConfigFile checks the config file and repo confs in its repo_conf_dir without connecting to github.
+// Plugin names, extra confs of plugins and references to templates, roles, aliases and labels are checked
+// on repo confs resolved with their templates and org defaults.
+func ValidateConfigFile(cfgFile string) []ConfigProblem {
+	v := &configValidator{
+		problems: make([]ConfigProblem, 0),
+	}
+
+	cfg, all, locations, ok := v.loadRepoConfs(cfgFile)
+	if !ok {
+		return v.problems
+	}
+	if cfg.InRepoConfPrecedence != "" && cfg.InRepoConfPrecedence != InRepoConfPrecedenceHost &&
+		cfg.InRepoConfPrecedence != InRepoConfPrecedenceRepo {
+		v.addf(cfgFile, "in_repo_conf_precedence", "should be %s or %s", InRepoConfPrecedenceHost, InRepoConfPrecedenceRepo)
+	}
+	for i, name := range cfg.InRepoConfPlugins {
+		if !plugin.IsRegistered(name) {
+			v.addf(cfgFile, fmt.Sprintf("in_repo_conf_plugins[%d]", i), "unknown plugin %q", name)
+		}
+	}
+
+	resolver := newRepoConfResolver(cfg.Templates, cfg.OrgDefaults)
+	for _, name := range sortedRepoKeys(cfg.Templates) {
+		if _, err := resolver.ResolveTemplates(RepoConf{Extends: []string{name}}); err != nil {
+			v.addf(cfgFile, fmt.Sprintf("templates[%q]", name), "%v", err)
+		}
+	}
+	for _, owner := range sortedRepoKeys(cfg.OrgDefaults) {
+		if _, err := resolver.ResolveTemplates(cfg.OrgDefaults[owner]); err != nil {
+			v.addf(cfgFile, fmt.Sprintf("org_defaults[%q]", owner), "%v", err)
+		}
+	}
+
+	for _, key := range sortedRepoKeys(all) {
+		loc := locations[key]
+		resolved, err := resolver.Resolve(key, all[key])
+		if err != nil {
+			v.addf(loc.file, loc.path, "%v", err)
+			continue
+		}
+		v.validateRepoConf(loc.file, loc.path, key, resolved)
+	}
+	return v.problems
+}
+
+// ResolveRepoConfFile returns the conf of repo fullName(owner/repo) resolved from the config file and
+// its repo_conf_dir, key is the repo conf matched. In-repo confs are not included.
+func ResolveRepoConfFile(cfgFile string, fullName string) (conf RepoConf, key string, err error) {
+	v := &configValidator{
+		problems: make([]ConfigProblem, 0),
+	}
+	cfg, all, _, ok := v.loadRepoConfs(cfgFile)
+	if !ok {
+		return RepoConf{}, "", fmt.Errorf("%s", v.problems[len(v.problems)-1].String())
+	}
+
+	resolver := newRepoConfResolver(cfg.Templates, cfg.OrgDefaults)
+	conf, key, ok = matchRepoConf(all, fullName)
+	if ok {
+		conf, err = resolver.Resolve(key, conf)
+		return
+	}
+
+	owner := strings.Split(fullName, "/")[0]
+	conf, ok, err = resolver.OrgDefault(owner)
+	if err != nil {
+		return
+	}
+	if !ok {
+		return RepoConf{}, "", fmt.Errorf("no conf of repo [%s]", fullName)
+	}
+	return conf, fmt.Sprintf("org_defaults[%s]", owner), nil
+}
+
+// loadRepoConfs loads the config file and merges repo confs in repo_confs and repo_conf_dir,
+// ok is false if the config can't be loaded.
+func (v *configValidator) loadRepoConfs(cfgFile string) (cfg Config, all map[string]RepoConf,
+	locations map[string]repoConfLocation, ok bool) {
+	if !v.loadFile(cfgFile, &cfg) {
+		return
+	}
+	v.checkUnknownFields(cfgFile, "repo_confs", cfg.RepoConfs)
+	v.checkUnknownFields(cfgFile, "templates", cfg.Templates)
+	v.checkUnknownFields(cfgFile, "org_defaults", cfg.OrgDefaults)
+
+	all = make(map[string]RepoConf)
+	locations = make(map[string]repoConfLocation)
+	for key, conf := range cfg.RepoConfs {
+		all[key] = conf
+		locations[key] = repoConfLocation{file: cfgFile, path: fmt.Sprintf("repo_confs[%q]", key)}
+	}
+
+	if cfg.RepoConfDir != "" {
+		files, err := ioutil.ReadDir(cfg.RepoConfDir)
+		if err != nil {
+			v.addf(cfgFile, "repo_conf_dir", "%v", err)
+			return cfg, all, locations, true
+		}
+
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+
+			fpath := filepath.Join(cfg.RepoConfDir, file.Name())
+			repoConfs := make(map[string]RepoConf)
+			if !v.loadFile(fpath, &repoConfs) {
+				continue
+			}
+			v.checkUnknownFields(fpath, "", repoConfs)
+			for _, key := range sortedRepoKeys(repoConfs) {
+				conf := repoConfs[key]
+				if exist, ok := all[key]; ok {
+					merged, err := mergeRepoConf(exist, conf)
+					if err != nil {
+						v.addf(fpath, fmt.Sprintf("[%q]", key), "%v", err)
+						continue
+					}
+					conf = merged
+				}
+				all[key] = conf
+				locations[key] = repoConfLocation{file: fpath, path: fmt.Sprintf("[%q]", key)}
+			}
+		}
+	}
+	return cfg, all, locations, true
+}
+
+// checkUnknownFields reports unknown fields in repo confs, they are not found when decoding the file
+// because RepoConf has its own UnmarshalJSON.
+func (v *configValidator) checkUnknownFields(file string, prefix string, repoConfs map[string]RepoConf) {
+	type plainRepoConf RepoConf
+	for _, key := range sortedRepoKeys(repoConfs) {
+		buf, err := json.Marshal(repoConfs[key].rawObject())
+		if err != nil {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(buf))
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(&plainRepoConf{}); err != nil {
+			v.addf(file, fmt.Sprintf("%s[%q]", prefix, key), "%s", strings.TrimPrefix(err.Error(), "json: "))
+		}
+	}
+}
+
+// loadFile decodes the file strictly, it falls back to the normal decoding if only unknown fields are found.
+func (v *configValidator) loadFile(file string, out interface{}) bool {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		v.addf(file, "", "%v", err)
+		return false
+	}
+	// references are not resolved since secrets may not exist where config is validated
+	for _, refErr := range config.CheckReferences(file, content) {
+		v.addf(file, refErr.Path, "%v", refErr.Err)
+	}
+
+	if err = config.UnmarshalStrict(file, content, out); err == nil {
+		return true
+	}
+	v.addf(file, "", "%v", err)
+	if !strings.Contains(err.Error(), "unknown field") {
+		return false
+	}
+
+	// reset out before decoding again
+	rv := reflect.ValueOf(out).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+	if err = config.Unmarshal(file, content, out); err != nil {
+		v.addf(file, "", "%v", err)
+		return false
+	}
+	return true
+}
+
+func (v *configValidator) validateRepoConf(file string, confPath string, key string, repoConf RepoConf) {
+	if isRepoPattern(key) {
+		if _, err := path.Match(key, ""); err != nil {
+			v.addf(file, confPath, "invalid repo pattern: %v", err)
+		}
+	} else if arrs := strings.Split(key, "/"); len(arrs) != 2 || arrs[0] == "" || arrs[1] == "" {
+		v.addf(file, confPath, "key should be {owner}/{repo} or a pattern")
+	}
+
+	if !config.IsValidFeedbackMode(repoConf.Feedback.Mode) {
+		v.addf(file, confPath+".feedback.mode", "should be %s, %s or %s",
+			config.FeedbackOff, config.FeedbackReaction, config.FeedbackComment)
+	}
+
+	v.validateAlias(file, confPath+".alias.cmds", repoConf.Alias.Cmds)
+	v.validateAlias(file, confPath+".alias.labels", repoConf.Alias.Labels)
+	v.validateAlias(file, confPath+".alias.users", repoConf.Alias.Users)
+
+	for _, role := range sortedStringKeys(repoConf.Roles) {
+		v.validateRoleEntries(file, fmt.Sprintf("%s.roles[%q]", confPath, role), repoConf.Roles[role])
+	}
+	for _, label := range sortedStringKeys(repoConf.LabelRoles) {
+		if arrs := strings.Split(label, "/"); len(arrs) != 2 || arrs[0] == "" || arrs[1] == "" {
+			v.addf(file, fmt.Sprintf("%s.label_roles[%q]", confPath, label), "label should be {prefix}/{name}")
+		}
+		for _, role := range sortedStringKeys(repoConf.LabelRoles[label]) {
+			v.validateRoleEntries(file, fmt.Sprintf("%s.label_roles[%q][%q]", confPath, label, role), repoConf.LabelRoles[label][role])
+		}
+	}
+
+	names := make([]string, 0, len(repoConf.Plugins))
+	for name := range repoConf.Plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pluginConf := repoConf.Plugins[name]
+		pluginPath := confPath + ".plugins." + name
+		if !plugin.IsRegistered(name) {
+			v.addf(file, pluginPath, "plugin [%s] is not registered", name)
+			continue
+		}
+
+		v.validatePreconditions(file, pluginPath+".preconditions", reflect.ValueOf(pluginConf.Preconditions), repoConf.Roles)
+
+		extra, ok := plugin.NewExtra(name)
+		if !ok || pluginConf.Extra == nil {
+			continue
+		}
+		extraPath := pluginPath + ".extra"
+		buf, err := json.Marshal(pluginConf.Extra)
+		if err != nil {
+			v.addf(file, extraPath, "%v", err)
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(buf))
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(extra); err != nil {
+			v.addf(file, extraPath, "%s", strings.TrimPrefix(err.Error(), "json: "))
+			continue
+		}
+
+		v.validatePreconditions(file, extraPath, reflect.ValueOf(extra), repoConf.Roles)
+
+		if c, ok := extra.(interface{ Complete() }); ok {
+			c.Complete()
+		}
+		if validator, ok := extra.(plugin.ExtraValidator); ok {
+			options := plugin.PluginOptions{}
+			options.Complete("", "", repoConf.Alias, repoConf.Roles, repoConf.LabelRoles, pluginConf.Preconditions, pluginConf.Extra)
+			for _, err := range validator.Validate(options) {
+				v.addf(file, extraPath, "%v", err)
+			}
+		}
+	}
+}
+
+// validateRoleEntries reports team references not in team:{org}/{slug} format.
+func (v *configValidator) validateRoleEntries(file string, rolePath string, entries []string) {
+	for i, entry := range entries {
+		if !strings.HasPrefix(entry, config.TeamRolePrefix) {
+			continue
+		}
+		if _, _, ok := config.ParseTeamRole(entry); !ok {
+			v.addf(file, fmt.Sprintf("%s[%d]", rolePath, i), "team [%s] should be %s{org}/{slug}", entry, config.TeamRolePrefix)
+		}
+	}
+}
+
+// validateAlias reports aliases pointing to another alias, only one level of alias is resolved.
+func (v *configValidator) validateAlias(file string, aliasPath string, alias map[string]string) {
+	for _, name := range sortedStringKeys(alias) {
+		target := alias[name]
+		if target == "" {
+			v.addf(file, fmt.Sprintf("%s[%q]", aliasPath, name), "alias target is empty")
+		} else if target == name {
+			v.addf(file, fmt.Sprintf("%s[%q]", aliasPath, name), "alias points to itself")
+		} else if _, ok := alias[target]; ok {
+			v.addf(file, fmt.Sprintf("%s[%q]", aliasPath, name), "alias target [%s] is also an alias, chained alias is not resolved", target)
+		}
+	}
+}
+
+var preconditionType = reflect.TypeOf(config.Precondition{})
+
+// validatePreconditions walks through rv and checks roles required by all preconditions found.
+func (v *configValidator) validatePreconditions(file string, valuePath string, rv reflect.Value, roles config.RoleOptions) {
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !rv.IsNil() {
+			v.validatePreconditions(file, valuePath, rv.Elem(), roles)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			v.validatePreconditions(file, fmt.Sprintf("%s[%d]", valuePath, i), rv.Index(i), roles)
+		}
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			v.validatePreconditions(file, fmt.Sprintf("%s[%q]", valuePath, fmt.Sprint(k.Interface())), rv.MapIndex(k), roles)
+		}
+	case reflect.Struct:
+		if rv.Type() == preconditionType {
+			pre := rv.Interface().(config.Precondition)
+			for _, role := range pre.RequiredRoles {
+				if _, ok := roles[role]; !ok {
+					v.addf(file, valuePath+".required_roles", "role [%s] is not defined in roles", role)
+				}
+			}
+			return
+		}
+
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			v.validatePreconditions(file, valuePath+"."+name, rv.Field(i), roles)
+		}
+	}
+}
+
+func sortedRepoKeys(m map[string]RepoConf) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedStringKeys(m interface{}) []string {
+	rv := reflect.ValueOf(m)
+	keys := make([]string, 0, rv.Len())
+	for _, k := range rv.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}